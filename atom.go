@@ -0,0 +1,156 @@
+package main
+
+import "strings"
+
+// AtomFeed is an Atom 1.0 (RFC 4287) <feed> document.
+type AtomFeed struct {
+	Title    AtomText     `xml:"title"`
+	Subtitle AtomText     `xml:"subtitle"`
+	Updated  string       `xml:"updated"`
+	Links    []AtomLink   `xml:"link"`
+	Authors  []AtomPerson `xml:"author"`
+	Entries  []AtomEntry  `xml:"entry"`
+}
+
+type AtomEntry struct {
+	ID         string         `xml:"id"`
+	Title      AtomText       `xml:"title"`
+	Links      []AtomLink     `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Summary    AtomText       `xml:"summary"`
+	Content    AtomText       `xml:"content"`
+	Authors    []AtomPerson   `xml:"author"`
+	Categories []AtomCategory `xml:"category"`
+}
+
+type AtomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type AtomCategory struct {
+	Term  string `xml:"term,attr"`
+	Label string `xml:"label,attr"`
+}
+
+type AtomPerson struct {
+	Name  string `xml:"name"`
+	Email string `xml:"email"`
+	URI   string `xml:"uri"`
+}
+
+// AtomText is an Atom text construct. Text and html content arrive as
+// character data, xhtml content as inline markup.
+type AtomText struct {
+	Type     string `xml:"type,attr"`
+	Text     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+func (t AtomText) String() string {
+	if t.Type == "xhtml" {
+		return strings.TrimSpace(t.InnerXML)
+	}
+	return strings.TrimSpace(t.Text)
+}
+
+// Plain returns the text with any markup removed and entities decoded, for
+// titles, which are stored as plain text whatever the feed format.
+func (t AtomText) Plain() string {
+	if t.Type == "html" || t.Type == "xhtml" {
+		return htmlToText(t.String())
+	}
+	return t.String()
+}
+
+// parseAtom decodes an Atom document and normalizes it into an RSSFeed.
+func parseAtom(body []byte) (*RSSFeed, error) {
+	var atom AtomFeed
+	if err := newXMLDecoder(body).Decode(&atom); err != nil {
+		return nil, err
+	}
+	feed := &RSSFeed{}
+	feed.Channel.Title = atom.Title.Plain()
+	feed.Channel.Link = atomAlternateLink(atom.Links)
+	feed.Channel.Description = atom.Subtitle.String()
+	feed.Channel.LastBuildDate = atom.Updated
+	for _, entry := range atom.Entries {
+		item := RSSItem{
+			Title:       entry.Title.Plain(),
+			Link:        atomAlternateLink(entry.Links),
+			Description: entry.Summary.String(),
+			Content:     entry.Content.String(),
+			PubDate:     entry.Published,
+			GUID:        strings.TrimSpace(entry.ID),
+			Author:      atomAuthors(entry.Authors),
+		}
+		if item.Description == "" {
+			item.Description = item.Content
+		}
+		if item.PubDate == "" {
+			item.PubDate = entry.Updated
+		}
+		if item.Author == "" {
+			item.Author = atomAuthors(atom.Authors)
+		}
+		for _, category := range entry.Categories {
+			// The label is only a display name; the term identifies it.
+			item.Categories = append(item.Categories, category.Term)
+		}
+		item.Categories = uniqueCategories(item.Categories)
+		item.CommentsURL = atomRepliesLink(entry.Links)
+		for _, link := range entry.Links {
+			if link.Rel == "enclosure" && link.Href != "" {
+				item.Enclosures = append(item.Enclosures, RSSEnclosure{
+					URL:    link.Href,
+					Type:   link.Type,
+					Length: link.Length,
+				})
+			}
+		}
+		feed.Channel.Item = append(feed.Channel.Item, item)
+	}
+	return feed, nil
+}
+
+// atomAlternateLink picks the link pointing at the human-readable page,
+// preferring rel="alternate" (the default when rel is omitted) over the rest.
+func atomAlternateLink(links []AtomLink) string {
+	for _, link := range links {
+		if link.Rel == "" || link.Rel == "alternate" {
+			return link.Href
+		}
+	}
+	if len(links) > 0 {
+		return links[0].Href
+	}
+	return ""
+}
+
+// atomRepliesLink returns the entry's comments page, published as a
+// rel="replies" link by the Atom threading extension (RFC 4685).
+func atomRepliesLink(links []AtomLink) string {
+	for _, link := range links {
+		if link.Rel == "replies" && (link.Type == "" || link.Type == "text/html") {
+			return link.Href
+		}
+	}
+	return ""
+}
+
+func atomAuthors(people []AtomPerson) string {
+	var names []string
+	for _, person := range people {
+		name := strings.TrimSpace(person.Name)
+		if name == "" {
+			name = strings.TrimSpace(person.Email)
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+const atomFixture = `<?xml version="1.0" encoding="utf-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <title type="html">Tom &amp;amp; Jerry</title>
+  <subtitle>Cartoons</subtitle>
+  <updated>2024-06-05T10:00:00Z</updated>
+  <link rel="self" href="https://example.com/feed.atom"/>
+  <link href="https://example.com/"/>
+  <author><name>Feed Author</name></author>
+  <entry>
+    <id>urn:uuid:1</id>
+    <title type="html">Cats &amp;amp; &lt;em&gt;dogs&lt;/em&gt;</title>
+    <link rel="replies" type="text/html" href="https://example.com/1#comments"/>
+    <link rel="alternate" href="https://example.com/1"/>
+    <link rel="enclosure" type="audio/mpeg" length="123" href="https://example.com/1.mp3"/>
+    <published>2024-06-05T09:00:00Z</published>
+    <updated>2024-06-05T09:30:00Z</updated>
+    <summary>Short</summary>
+    <content type="xhtml"><div xmlns="http://www.w3.org/1999/xhtml"><p>Long</p></div></content>
+    <author><name>Jo</name></author>
+    <author><email>sam@example.com</email></author>
+    <category term="pets" label="Pets"/>
+    <category term="Pets"/>
+  </entry>
+  <entry>
+    <id>urn:uuid:2</id>
+    <title>Plain &amp; simple</title>
+    <link href="https://example.com/2"/>
+    <updated>2024-06-04T09:00:00Z</updated>
+    <content type="html">&lt;p&gt;Body&lt;/p&gt;</content>
+  </entry>
+</feed>`
+
+func TestParseAtom(t *testing.T) {
+	feed, err := parseFeed("application/atom+xml", []byte(atomFixture))
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	if got := feed.Channel.Title; got != "Tom & Jerry" {
+		t.Errorf("feed title %q", got)
+	}
+	if got := feed.Channel.Link; got != "https://example.com/" {
+		t.Errorf("feed link %q", got)
+	}
+	if got := feed.Channel.Description; got != "Cartoons" {
+		t.Errorf("feed description %q", got)
+	}
+	if len(feed.Channel.Item) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Channel.Item))
+	}
+
+	first := feed.Channel.Item[0]
+	tests := []struct {
+		field, got, want string
+	}{
+		{"title", first.Title, "Cats & dogs"},
+		{"link", first.Link, "https://example.com/1"},
+		{"guid", first.GUID, "urn:uuid:1"},
+		{"pubDate", first.PubDate, "2024-06-05T09:00:00Z"},
+		{"description", first.Description, "Short"},
+		{"content", first.Content, `<div xmlns="http://www.w3.org/1999/xhtml"><p>Long</p></div>`},
+		{"author", first.Author, "Jo, sam@example.com"},
+		{"comments", first.CommentsURL, "https://example.com/1#comments"},
+	}
+	second := feed.Channel.Item[1]
+	tests = append(tests, []struct {
+		field, got, want string
+	}{
+		{"second title", second.Title, "Plain & simple"},
+		{"second link", second.Link, "https://example.com/2"},
+		{"second pubDate", second.PubDate, "2024-06-04T09:00:00Z"},
+		{"second content", second.Content, "<p>Body</p>"},
+		{"second description", second.Description, "<p>Body</p>"},
+		{"second author", second.Author, "Feed Author"},
+	}...)
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.field, tt.got, tt.want)
+		}
+	}
+	if !slices.Equal(first.Categories, []string{"pets"}) {
+		t.Errorf("categories %q, want [pets]", first.Categories)
+	}
+	if len(first.Enclosures) != 1 || first.Enclosures[0].URL != "https://example.com/1.mp3" ||
+		first.Enclosures[0].Type != "audio/mpeg" || first.Enclosures[0].Length != "123" {
+		t.Errorf("enclosures %+v", first.Enclosures)
+	}
+}
+
+func TestAtomTextPlain(t *testing.T) {
+	tests := []struct {
+		text AtomText
+		want string
+	}{
+		{AtomText{Text: " a &amp; b "}, "a &amp; b"},
+		{AtomText{Type: "text", Text: "a & b"}, "a & b"},
+		{AtomText{Type: "html", Text: "a &amp; b"}, "a & b"},
+		{AtomText{Type: "html", Text: "<b>bold</b> move"}, "bold move"},
+		{AtomText{Type: "xhtml", InnerXML: `<div xmlns="http://www.w3.org/1999/xhtml">x &amp; <i>y</i></div>`}, "x & y"},
+	}
+	for _, tt := range tests {
+		if got := tt.text.Plain(); got != tt.want {
+			t.Errorf("%+v.Plain() = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestParseAtomInvalid(t *testing.T) {
+	for _, body := range []string{
+		`<feed xmlns="http://www.w3.org/2005/Atom"><entry><title>unclosed</entry></feed>`,
+		`<feed xmlns="http://www.w3.org/2005/Atom">`,
+	} {
+		if _, err := parseFeed("application/atom+xml", []byte(body)); err == nil {
+			t.Errorf("parseFeed(%q) succeeded, want an error", body)
+		}
+	}
+}
@@ -1,17 +1,25 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"database/sql"
-	"encoding/xml"
+	"errors"
 	"fmt"
-	"html"
 	"io"
-	"net/http"
+	"log"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/Specter242/Gator/internal/config"
 	"github.com/Specter242/Gator/internal/database"
+	"github.com/Specter242/Gator/internal/dateparse"
+	"golang.org/x/term"
 )
 
 type state struct {
@@ -28,22 +36,6 @@ type commands struct {
 	Commandmap map[string]func(*state, command) error
 }
 
-type RSSFeed struct {
-	Channel struct {
-		Title       string    `xml:"title"`
-		Link        string    `xml:"link"`
-		Description string    `xml:"description"`
-		Item        []RSSItem `xml:"item"`
-	} `xml:"channel"`
-}
-
-type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	PubDate     string `xml:"pubDate"`
-}
-
 func (c *commands) register(name string, f func(*state, command) error) {
 	if c.Commandmap == nil {
 		c.Commandmap = make(map[string]func(*state, command) error)
@@ -58,6 +50,54 @@ func (c *commands) run(s *state, cmd command) error {
 	return fmt.Errorf("unknown command: %s", cmd.Name)
 }
 
+// parseFlags separates --name value (or --name=value) flags from positional
+// arguments. Flags named in boolFlags take no value and are recorded as
+// "true"; any flag not named in valueFlags or boolFlags is an error.
+func parseFlags(args []string, valueFlags, boolFlags []string) ([]string, map[string]string, error) {
+	var positional []string
+	flags := make(map[string]string)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "--") {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		switch {
+		case slices.Contains(boolFlags, name):
+			if hasValue {
+				return nil, nil, fmt.Errorf("flag --%s takes no value", name)
+			}
+			flags[name] = "true"
+		case slices.Contains(valueFlags, name):
+			if !hasValue {
+				if i+1 >= len(args) {
+					return nil, nil, fmt.Errorf("flag --%s needs a value", name)
+				}
+				i++
+				value = args[i]
+			}
+			flags[name] = value
+		default:
+			return nil, nil, fmt.Errorf("unknown flag: --%s", name)
+		}
+	}
+	return positional, flags, nil
+}
+
+// intFlag returns the positive integer value of a flag, or def when unset.
+func intFlag(flags map[string]string, name string, def int) (int, error) {
+	value, ok := flags[name]
+	if !ok {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("invalid value for --%s: %s", name, value)
+	}
+	return n, nil
+}
+
 func handlerLogin(s *state, cmd command) error {
 	if len(cmd.Args) != 1 {
 		return fmt.Errorf("usage: %s <username>", cmd.Name)
@@ -141,36 +181,90 @@ func handlerGetUsers(s *state, cmd command) error {
 }
 
 func handlerFetchFeed(s *state, cmd command) error {
-	if len(cmd.Args) != 1 {
-		return fmt.Errorf("usage: %s <time_between_requests>", cmd.Name)
+	usage := fmt.Errorf("usage: %s <time_between_requests> [--workers n] [--per-host n] [--prune interval]", cmd.Name)
+	args, flags, err := parseFlags(cmd.Args, []string{"workers", "per-host", "prune"}, nil)
+	if err != nil || len(args) != 1 {
+		return usage
 	}
-	tick, err := time.ParseDuration(cmd.Args[0])
+	tick, err := time.ParseDuration(args[0])
 	if err != nil {
 		return fmt.Errorf("invalid time format: %v", err)
 	}
-	println("Collecting feeds every %s\n", tick)
+	workers, err := intFlag(flags, "workers", defaultAggWorkers)
+	if err != nil {
+		return err
+	}
+	perHost, err := intFlag(flags, "per-host", defaultAggPerHost)
+	if err != nil {
+		return err
+	}
+	var pruneEvery time.Duration
+	if arg, ok := flags["prune"]; ok {
+		pruneEvery, err = time.ParseDuration(arg)
+		if err != nil || pruneEvery < time.Minute {
+			return fmt.Errorf("invalid prune interval %q: use a duration of at least 1m", arg)
+		}
+	}
+	retention, err := defaultRetention(s.Config)
+	if err != nil {
+		return err
+	}
+	agg := newAggregator(s, workers, perHost, retention)
+	fmt.Printf("Collecting feeds every %s with %d workers\n", tick, workers)
 	ticker := time.NewTicker(tick)
 	defer ticker.Stop()
+	var lastPrune time.Time
 	for ; ; <-ticker.C {
-		handlerScrapeFeeds(s, command{Name: "scrapefeeds"})
+		summary, err := agg.runCycle(context.Background())
+		if err != nil {
+			log.Printf("aggregation cycle failed: %v", err)
+		} else {
+			log.Print(summary)
+		}
+		if pruneEvery > 0 && time.Since(lastPrune) >= pruneEvery {
+			lastPrune = time.Now()
+			if err := pruneAll(context.Background(), s); err != nil {
+				log.Printf("pruning failed: %v", err)
+			}
+		}
 	}
 }
 
 func handlerAddFeed(s *state, cmd command, user database.User) error {
-	if len(cmd.Args) != 2 {
-		return fmt.Errorf("usage: %s <name> <feed_url>", cmd.Name)
+	args, flags, err := parseFlags(cmd.Args, []string{"interval"}, nil)
+	if err != nil || len(args) != 2 {
+		return fmt.Errorf("usage: %s <name> <feed_url> [--interval <duration|adaptive>]", cmd.Name)
+	}
+	feedName := args[0]
+	feedURL := args[1]
+	var interval sql.NullInt32
+	var adaptive bool
+	if arg, ok := flags["interval"]; ok {
+		interval, adaptive, err = parseSchedule(arg)
+		if err != nil {
+			return err
+		}
 	}
-	feedName := cmd.Args[0]
-	feedURL := cmd.Args[1]
 	ctx := context.Background()
 	feed, err := fetchFeed(ctx, feedURL)
+	var page *htmlPageError
+	if errors.As(err, &page) {
+		// A web page rather than a feed: offer the feeds it points to.
+		feedURL, err = chooseDiscoveredFeed(ctx, page, os.Stdin)
+		if err != nil {
+			return err
+		}
+		feed, err = fetchFeed(ctx, feedURL)
+	}
 	if err != nil {
 		return fmt.Errorf("error fetching feed: %v", err)
 	}
 	feedRow, err := s.db.CreateFeed(ctx, database.CreateFeedParams{
-		Name:   feedName,
-		Url:    feedURL,
-		UserID: user.ID,
+		Name:                 feedName,
+		Url:                  feedURL,
+		UserID:               user.ID,
+		FetchIntervalSeconds: interval,
+		AdaptiveSchedule:     adaptive,
 	})
 	if err != nil {
 		return fmt.Errorf("error creating feed: %v", err)
@@ -186,9 +280,41 @@ func handlerAddFeed(s *state, cmd command, user database.User) error {
 	return nil
 }
 
+// chooseDiscoveredFeed looks for the feeds of an HTML page and returns the
+// URL of the one to add, asking on in when there is more than one.
+func chooseDiscoveredFeed(ctx context.Context, page *htmlPageError, in io.Reader) (string, error) {
+	fmt.Printf("%s is a web page, looking for its feeds...\n", page.URL)
+	candidates, err := discoverFeeds(ctx, page.URL, page.Body)
+	if err != nil {
+		return "", err
+	}
+	switch len(candidates) {
+	case 0:
+		return "", fmt.Errorf("no feeds found for %s", page.URL)
+	case 1:
+		fmt.Printf("Found feed: %s (%s)\n", candidates[0].Title, candidates[0].URL)
+		return candidates[0].URL, nil
+	}
+	fmt.Println("Found several feeds:")
+	for i, c := range candidates {
+		fmt.Printf("  %d. %s (%s)\n", i+1, c.Title, c.URL)
+	}
+	fmt.Printf("Choose a feed [1-%d]: ", len(candidates))
+	line, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && line == "" {
+		return "", fmt.Errorf("no feed chosen; run addfeed again with one of the URLs above")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil || n < 1 || n > len(candidates) {
+		return "", fmt.Errorf("invalid choice: %s", strings.TrimSpace(line))
+	}
+	return candidates[n-1].URL, nil
+}
+
 func handlerFeeds(s *state, cmd command) error {
-	if len(cmd.Args) != 0 {
-		return fmt.Errorf("usage: %s", cmd.Name)
+	args, flags, err := parseFlags(cmd.Args, nil, []string{"health"})
+	if err != nil || len(args) != 0 {
+		return fmt.Errorf("usage: %s [--health]", cmd.Name)
 	}
 	feeds, err := s.db.GetFeeds(context.Background(), database.GetFeedsParams{
 		Limit:  100,
@@ -197,6 +323,10 @@ func handlerFeeds(s *state, cmd command) error {
 	if err != nil {
 		return fmt.Errorf("error getting feeds: %v", err)
 	}
+	if flags["health"] != "" {
+		printFeedHealth(feeds)
+		return nil
+	}
 	for _, feed := range feeds {
 		ctx := context.Background()
 		user, err := s.db.GetUserById(ctx, feed.UserID)
@@ -209,6 +339,38 @@ func handlerFeeds(s *state, cmd command) error {
 	return nil
 }
 
+func printFeedHealth(feeds []database.Feed) {
+	for _, feed := range feeds {
+		fmt.Printf("- %s (%s): %s\n", feed.Name, feed.Url, feedHealth(feed))
+		if feed.LastSuccessAt.Valid {
+			fmt.Printf("    last success: %s\n", feed.LastSuccessAt.Time.Format(time.RFC1123))
+		}
+		if feed.LastStatus.Valid {
+			fmt.Printf("    last status:  %d\n", feed.LastStatus.Int32)
+		}
+		if feed.LastError.Valid {
+			fmt.Printf("    last error:   %s\n", feed.LastError.String)
+		}
+		if feed.LastSuccessAt.Valid {
+			fmt.Printf("    last scrape:  %d new, %d updated, %d skipped\n",
+				feed.LastScrapeInserted, feed.LastScrapeUpdated, feed.LastScrapeSkipped)
+		}
+		if feed.LastScrapeErrors.Valid {
+			for _, line := range strings.Split(feed.LastScrapeErrors.String, "\n") {
+				fmt.Printf("      %s\n", line)
+			}
+		}
+		if feed.MovedToUrl.Valid && feed.MovedCount == 0 {
+			fmt.Printf("    moved to:     %s (blocked: another feed has this URL)\n", feed.MovedToUrl.String)
+		}
+		if feed.DisabledAt.Valid {
+			fmt.Printf("    disabled at:  %s\n", feed.DisabledAt.Time.Format(time.RFC1123))
+		} else if feed.NextFetchAt.Valid {
+			fmt.Printf("    next fetch:   %s\n", feed.NextFetchAt.Time.Format(time.RFC1123))
+		}
+	}
+}
+
 func handlerFollow(s *state, cmd command, user database.User) error {
 	if len(cmd.Args) != 1 {
 		return fmt.Errorf("usage: %s <url>", cmd.Name)
@@ -262,8 +424,30 @@ func handlerFollowing(s *state, cmd command, user database.User) error {
 		return fmt.Errorf("error getting followed feeds: %v", err)
 	}
 	fmt.Printf("%s is following:\n", user.Name)
+	// Group by folder, listing a feed under each of its folders and the
+	// feeds in none at the end.
+	byFolder := make(map[string][]database.GetFeedFollowsForUserRow)
+	var folders, unfiled []string
 	for _, feed := range followedFeeds {
-		fmt.Printf("- %s\n", feed.FeedName)
+		if len(feed.Folders) == 0 {
+			unfiled = append(unfiled, fmt.Sprintf("- %s (%d unread)", feed.FeedName, feed.UnreadCount))
+		}
+		for _, folder := range feed.Folders {
+			if _, ok := byFolder[folder]; !ok {
+				folders = append(folders, folder)
+			}
+			byFolder[folder] = append(byFolder[folder], feed)
+		}
+	}
+	slices.Sort(folders)
+	for _, folder := range folders {
+		fmt.Printf("%s/\n", folder)
+		for _, feed := range byFolder[folder] {
+			fmt.Printf("  - %s (%d unread)\n", feed.FeedName, feed.UnreadCount)
+		}
+	}
+	for _, line := range unfiled {
+		fmt.Println(line)
 	}
 	return nil
 }
@@ -302,6 +486,53 @@ func handlerUnfollow(s *state, cmd command, user database.User) error {
 	return nil
 }
 
+func handlerSetInterval(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("usage: %s <feed_name_or_url> <duration|adaptive|default>", cmd.Name)
+	}
+	interval, adaptive, err := parseSchedule(cmd.Args[1])
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	feed, err := s.db.GetFeedByNameOrURL(ctx, cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("feed not found: %s", cmd.Args[0])
+	}
+	if feed.UserID != user.ID {
+		return fmt.Errorf("only the user who added %s can change its schedule", feed.Name)
+	}
+	err = s.db.SetFeedSchedule(ctx, database.SetFeedScheduleParams{
+		ID:                   feed.ID,
+		FetchIntervalSeconds: interval,
+		AdaptiveSchedule:     adaptive,
+	})
+	if err != nil {
+		return fmt.Errorf("error setting feed schedule: %v", err)
+	}
+	fmt.Printf("Feed %s will be fetched %s\n", feed.Name, describeSchedule(interval, adaptive))
+	return nil
+}
+
+func handlerEnableFeed(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <feed_name_or_url>", cmd.Name)
+	}
+	ctx := context.Background()
+	feed, err := s.db.GetFeedByNameOrURL(ctx, cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("feed not found: %s", cmd.Args[0])
+	}
+	if feed.UserID != user.ID {
+		return fmt.Errorf("only the user who added %s can re-enable it", feed.Name)
+	}
+	if err := s.db.EnableFeed(ctx, feed.ID); err != nil {
+		return fmt.Errorf("error enabling feed: %v", err)
+	}
+	fmt.Printf("Feed %s enabled and due for fetching\n", feed.Name)
+	return nil
+}
+
 func handlerHelp(s *state, cmd command) error {
 	if len(cmd.Args) != 0 {
 		return fmt.Errorf("usage: %s", cmd.Name)
@@ -311,12 +542,33 @@ func handlerHelp(s *state, cmd command) error {
 	fmt.Println("  register <username> - Register a new user")
 	fmt.Println("  reset - Reset the database")
 	fmt.Println("  users - Get all users")
-	fmt.Println("  agg - run aggregator service")
-	fmt.Println("  addfeed <name> <url> - Add a new feed with the specified name and URL")
-	fmt.Println("  feeds - List all feeds")
+	fmt.Println("  agg <interval> [--workers n] [--per-host n] [--prune interval] - run aggregator service")
+	fmt.Println("  addfeed <name> <url> [--interval <duration|adaptive>] - Add a new feed by its URL or its site's page")
+	fmt.Println("  setinterval <feed> <duration|adaptive|default> - Change how often a feed is fetched")
+	fmt.Println("  feeds [--health] - List all feeds, or their fetch health")
+	fmt.Println("  enablefeed <feed> - Re-enable a feed disabled after repeated failures")
 	fmt.Println("  follow <url> - Follow a feed by URL")
 	fmt.Println("  following - List all followed feeds")
 	fmt.Println("  unfollow <url> - Unfollow a feed by URL")
+	fmt.Println("  scrapefeeds - Scrape all due feeds once")
+	fmt.Println("  prune [feed] - Delete posts past their retention limits, keeping starred posts")
+	fmt.Println("  retention [feed] [--max-age age] [--max-posts n] - Show or set the default or a feed's retention limits")
+	fmt.Println("  browse [limit] [feed] [--author name] [--category name] [--folder name] [--unread] - Browse posts from followed feeds")
+	fmt.Println("  read <post_id> - Show a post and mark it read")
+	fmt.Println("  mark-read <feed> [--before date] | --before date | --all - Mark posts as read")
+	fmt.Println("  star <post_id> - Star a post to keep it")
+	fmt.Println("  unstar <post_id> - Remove a post's star")
+	fmt.Println("  starred [limit] - List starred posts")
+	fmt.Println("  search <query> [--limit n] [--folder name] - Search posts in followed feeds")
+	fmt.Println("  tag <feed> <folder> - Add a followed feed to a folder")
+	fmt.Println("  untag <feed> <folder> - Remove a feed from a folder")
+	fmt.Println("  folders - List your folders")
+	fmt.Println("  download <post_id|feed> [dir] [--limit n] - Download enclosures, resuming partial downloads")
+	fmt.Println("  import <file.opml> - Import and follow the feeds in an OPML file")
+	fmt.Println("  export [file] - Export followed feeds as OPML")
+	fmt.Println("  serve [--addr host:port] - Serve the JSON, Fever and Google Reader APIs")
+	fmt.Println("  token create <name> | list | revoke <id> - Manage your API tokens")
+	fmt.Println("  fever enable | disable - Set (prompted, or read from stdin) or remove your password for Fever and Google Reader clients")
 	fmt.Println("  help - Show this help message")
 	return nil
 }
@@ -325,113 +577,922 @@ func handlerScrapeFeeds(s *state, cmd command) error {
 	if len(cmd.Args) != 0 {
 		return fmt.Errorf("usage: %s", cmd.Name)
 	}
-	next, err := s.db.GetNextFeedToFetch(context.Background())
+	retention, err := defaultRetention(s.Config)
 	if err != nil {
-		return fmt.Errorf("error getting next feed to fetch: %v", err)
+		return err
 	}
-	err = s.db.LastFetchedAt(context.Background(), next.ID)
+	agg := newAggregator(s, defaultAggWorkers, defaultAggPerHost, retention)
+	summary, err := agg.runCycle(context.Background())
 	if err != nil {
-		return fmt.Errorf("error marking feed as fetched: %v", err)
+		return err
 	}
-	fetch, err := fetchFeed(context.Background(), next.Url)
+	fmt.Println(summary)
+	return nil
+}
+
+func handlerPrune(s *state, cmd command) error {
+	if len(cmd.Args) > 1 {
+		return fmt.Errorf("usage: %s [feed_name_or_url]", cmd.Name)
+	}
+	ctx := context.Background()
+	var feeds []database.GetFeedRetentionsRow
+	if len(cmd.Args) == 1 {
+		feed, err := s.db.GetFeedByNameOrURL(ctx, cmd.Args[0])
+		if err != nil {
+			return fmt.Errorf("feed not found: %s", cmd.Args[0])
+		}
+		feeds = append(feeds, database.GetFeedRetentionsRow{
+			ID:                     feed.ID,
+			Name:                   feed.Name,
+			RetentionMaxAgeSeconds: feed.RetentionMaxAgeSeconds,
+			RetentionMaxPosts:      feed.RetentionMaxPosts,
+		})
+	} else {
+		var err error
+		feeds, err = s.db.GetFeedRetentions(ctx)
+		if err != nil {
+			return fmt.Errorf("error getting feeds: %v", err)
+		}
+	}
+	results, err := pruneFeeds(ctx, s, feeds)
 	if err != nil {
-		return fmt.Errorf("error fetching feed: %v", err)
+		return err
+	}
+	if len(results) == 0 {
+		fmt.Println("No retention limits set; nothing to prune")
+		return nil
+	}
+	var removed int64
+	failed := 0
+	for _, res := range results {
+		removed += res.Removed
+		if res.Err != nil {
+			failed++
+			fmt.Printf("- %s: %d removed, then failed: %v\n", res.Name, res.Removed, res.Err)
+		} else if res.Removed > 0 {
+			fmt.Printf("- %s: %d removed\n", res.Name, res.Removed)
+		}
+	}
+	fmt.Printf("Removed %d posts from %d feeds; starred posts are always kept\n", removed, len(results))
+	if failed > 0 {
+		return fmt.Errorf("pruning failed for %d feeds", failed)
 	}
-	for _, item := range fetch.Channel.Item {
-		var pubTime time.Time
-		var parseErr error
-		layouts := []string{
-			time.RFC1123Z, time.RFC1123, time.RFC822Z, time.RFC822,
+	return nil
+}
+
+func handlerRetention(s *state, cmd command, user database.User) error {
+	args, flags, err := parseFlags(cmd.Args, []string{"max-age", "max-posts"}, nil)
+	if err != nil || len(args) > 1 {
+		return fmt.Errorf("usage: %s [feed_name_or_url] [--max-age <age|off|default>] [--max-posts <n|off|default>]", cmd.Name)
+	}
+	def, err := defaultRetention(s.Config)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return setDefaultRetention(s, def, flags)
+	}
+	ctx := context.Background()
+	feed, err := s.db.GetFeedByNameOrURL(ctx, args[0])
+	if err != nil {
+		return fmt.Errorf("feed not found: %s", args[0])
+	}
+	if len(flags) == 0 {
+		fmt.Printf("Posts of %s are %s\n", feed.Name, feedRetention(def, feed.RetentionMaxAgeSeconds, feed.RetentionMaxPosts))
+		return nil
+	}
+	if feed.UserID != user.ID {
+		return fmt.Errorf("only the user who added %s can change its retention", feed.Name)
+	}
+	maxAge, maxPosts := feed.RetentionMaxAgeSeconds, feed.RetentionMaxPosts
+	if arg, ok := flags["max-age"]; ok {
+		maxAge = sql.NullInt32{}
+		if arg != "default" {
+			age, err := parseRetentionAge(arg)
+			if err != nil {
+				return err
+			}
+			maxAge = sql.NullInt32{Int32: int32(age / time.Second), Valid: true}
+		}
+	}
+	if arg, ok := flags["max-posts"]; ok {
+		maxPosts = sql.NullInt32{}
+		if arg != "default" {
+			n, err := parseRetentionPosts(arg)
+			if err != nil {
+				return err
+			}
+			maxPosts = sql.NullInt32{Int32: int32(n), Valid: true}
+		}
+	}
+	err = s.db.SetFeedRetention(ctx, database.SetFeedRetentionParams{
+		ID:                     feed.ID,
+		RetentionMaxAgeSeconds: maxAge,
+		RetentionMaxPosts:      maxPosts,
+	})
+	if err != nil {
+		return fmt.Errorf("error setting feed retention: %v", err)
+	}
+	fmt.Printf("Posts of %s are now %s\n", feed.Name, feedRetention(def, maxAge, maxPosts))
+	return nil
+}
+
+// setDefaultRetention shows or changes the retention policy in the config
+// file, which applies to feeds without their own.
+func setDefaultRetention(s *state, def retentionPolicy, flags map[string]string) error {
+	if len(flags) == 0 {
+		fmt.Printf("By default, posts are %s\n", def)
+		return nil
+	}
+	if arg, ok := flags["max-age"]; ok {
+		age, err := parseRetentionAge(arg)
+		if err != nil {
+			return err
+		}
+		def.MaxAge = age
+		s.Config.RetentionMaxAge = ""
+		if age > 0 {
+			s.Config.RetentionMaxAge = formatRetentionAge(age)
+		}
+	}
+	if arg, ok := flags["max-posts"]; ok {
+		n, err := parseRetentionPosts(arg)
+		if err != nil {
+			return err
+		}
+		def.MaxPosts = n
+		s.Config.RetentionMaxPosts = n
+	}
+	homeDir, err := config.GetHomeDir()
+	if err != nil {
+		return fmt.Errorf("error getting home directory: %v", err)
+	}
+	if err := config.Write(homeDir, *s.Config); err != nil {
+		return fmt.Errorf("error writing config: %v", err)
+	}
+	fmt.Printf("By default, posts are now %s\n", def)
+	return nil
+}
+
+func handlerServe(s *state, cmd command) error {
+	args, flags, err := parseFlags(cmd.Args, []string{"addr"}, nil)
+	if err != nil || len(args) != 0 {
+		return fmt.Errorf("usage: %s [--addr host:port]", cmd.Name)
+	}
+	addr := flags["addr"]
+	if addr == "" {
+		addr = defaultServeAddr
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	fmt.Printf("Serving the API on %s under /api/v1\n", addr)
+	return serveAPI(ctx, s, addr)
+}
+
+func handlerToken(s *state, cmd command, user database.User) error {
+	usage := fmt.Errorf("usage: %s create <name> | list | revoke <id>", cmd.Name)
+	if len(cmd.Args) == 0 {
+		return usage
+	}
+	ctx := context.Background()
+	switch cmd.Args[0] {
+	case "create":
+		if len(cmd.Args) != 2 {
+			return usage
+		}
+		token, hash, err := newAPIToken()
+		if err != nil {
+			return fmt.Errorf("error generating token: %v", err)
+		}
+		row, err := s.db.CreateAPIToken(ctx, database.CreateAPITokenParams{
+			UserID:    user.ID,
+			Name:      cmd.Args[1],
+			TokenHash: hash,
+		})
+		if err != nil {
+			return fmt.Errorf("error creating token: %v", err)
+		}
+		fmt.Printf("Created token #%d (%s) for %s:\n%s\n", row.ID, row.Name, user.Name, token)
+		fmt.Println("It won't be shown again; send it as \"Authorization: Bearer <token>\"")
+	case "list":
+		if len(cmd.Args) != 1 {
+			return usage
+		}
+		tokens, err := s.db.GetAPITokensForUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("error getting tokens: %v", err)
+		}
+		if len(tokens) == 0 {
+			fmt.Println("No API tokens")
 		}
-		for _, layout := range layouts {
-			pubTime, parseErr = time.Parse(layout, item.PubDate)
-			if parseErr == nil {
-				break
+		for _, token := range tokens {
+			lastUsed := "never used"
+			if token.LastUsedAt.Valid {
+				lastUsed = "last used " + token.LastUsedAt.Time.Format(time.RFC1123)
 			}
+			fmt.Printf("- #%d %s: created %s, %s\n", token.ID, token.Name, token.CreatedAt.Format(time.RFC1123), lastUsed)
+		}
+	case "revoke":
+		if len(cmd.Args) != 2 {
+			return usage
+		}
+		id, err := strconv.Atoi(cmd.Args[1])
+		if err != nil {
+			return fmt.Errorf("invalid token id: %s", cmd.Args[1])
+		}
+		n, err := s.db.DeleteAPIToken(ctx, database.DeleteAPITokenParams{
+			ID:     int32(id),
+			UserID: user.ID,
+		})
+		if err != nil {
+			return fmt.Errorf("error revoking token: %v", err)
+		}
+		if n == 0 {
+			return fmt.Errorf("token #%d not found", id)
+		}
+		fmt.Printf("Revoked token #%d\n", id)
+	default:
+		return usage
+	}
+	return nil
+}
+
+func handlerFever(s *state, cmd command, user database.User) error {
+	usage := fmt.Errorf("usage: %s enable | disable", cmd.Name)
+	if len(cmd.Args) == 0 {
+		return usage
+	}
+	ctx := context.Background()
+	switch cmd.Args[0] {
+	case "enable":
+		if len(cmd.Args) != 1 {
+			return usage
 		}
-		if parseErr != nil {
-			return fmt.Errorf("error parsing pubDate %q: %v", item.PubDate, parseErr)
+		password, err := readFeverPassword()
+		if err != nil {
+			return err
 		}
-		_, err := s.db.CreatePost(context.Background(), database.CreatePostParams{
-			FeedID:      next.ID,
-			Title:       item.Title,
-			Url:         item.Link,
-			Description: sql.NullString{String: item.Description, Valid: item.Description != ""},
-			PublishedAt: pubTime,
+		err = s.db.SetFeverKey(ctx, database.SetFeverKeyParams{
+			UserID:  user.ID,
+			KeyHash: hashAPIToken(feverAPIKey(user.Name, password)),
 		})
 		if err != nil {
-			return fmt.Errorf("error creating post: %v", err)
+			return fmt.Errorf("error setting Fever password: %v", err)
+		}
+		fmt.Printf("Fever and Google Reader access enabled: run serve, then sign in as %s with this password\n", user.Name)
+		fmt.Printf("Fever readers use http://<host>%s/fever/ and Google Reader ones http://<host>%s\n", defaultServeAddr, defaultServeAddr)
+	case "disable":
+		if len(cmd.Args) != 1 {
+			return usage
+		}
+		n, err := s.db.DeleteFeverKey(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("error disabling Fever password: %v", err)
+		}
+		if n == 0 {
+			fmt.Println("Fever and Google Reader access was not enabled")
+			return nil
 		}
-		fmt.Printf("Post created: %s\n", item.Title)
+		fmt.Println("Fever and Google Reader access disabled; revoke signed-in Google Reader clients with token revoke")
+	default:
+		return usage
 	}
 	return nil
 }
 
-func handlerBrowse(s *state, cmd command) error {
+// readFeverPassword reads the password for fever enable, so it never shows
+// up in the process list or shell history. On a terminal it is typed twice
+// without echo; otherwise it is the first line of stdin.
+func readFeverPassword() (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("error reading password from stdin: %v", err)
+		}
+		password := strings.TrimRight(line, "\r\n")
+		if password == "" {
+			return "", errors.New("password must not be empty")
+		}
+		return password, nil
+	}
+	var entries [2]string
+	for i, prompt := range []string{"Fever password: ", "Repeat password: "} {
+		fmt.Print(prompt)
+		entry, err := term.ReadPassword(fd)
+		fmt.Println()
+		if err != nil {
+			return "", fmt.Errorf("error reading password: %v", err)
+		}
+		entries[i] = string(entry)
+	}
+	if entries[0] == "" {
+		return "", errors.New("password must not be empty")
+	}
+	if entries[0] != entries[1] {
+		return "", errors.New("passwords don't match")
+	}
+	return entries[0], nil
+}
+
+func handlerBrowse(s *state, cmd command, user database.User) error {
+	usage := fmt.Errorf("usage: %s [limit] [feed_name_or_url] [--author name] [--category name] [--folder name] [--unread]", cmd.Name)
+	args, flags, err := parseFlags(cmd.Args, []string{"author", "category", "folder"}, []string{"unread"})
+	if err != nil {
+		return err
+	}
 	limit := 2
+	var feed sql.NullString
+	switch len(args) {
+	case 0:
+	case 1:
+		// A lone argument is a limit when it is numeric, otherwise a feed.
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			limit = n
+		} else {
+			feed = sql.NullString{String: args[0], Valid: true}
+		}
+	case 2:
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid limit: %s", args[0])
+		}
+		limit = n
+		feed = sql.NullString{String: args[1], Valid: true}
+	default:
+		return usage
+	}
+	if limit <= 0 {
+		return fmt.Errorf("invalid limit: %d", limit)
+	}
+	author, hasAuthor := flags["author"]
+	category, hasCategory := flags["category"]
+	folder, err := folderFlag(flags)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	posts, err := s.db.GetPostsForUser(ctx, database.GetPostsForUserParams{
+		UserID:     user.ID,
+		Feed:       feed,
+		Author:     sql.NullString{String: author, Valid: hasAuthor},
+		Category:   sql.NullString{String: category, Valid: hasCategory},
+		Folder:     folder,
+		UnreadOnly: flags["unread"] != "",
+		Limit:      int32(limit),
+		Offset:     0,
+	})
+	if err != nil {
+		return fmt.Errorf("error getting posts: %v", err)
+	}
+	if len(posts) == 0 {
+		fmt.Println("No posts found.")
+		return nil
+	}
+	postIDs := make([]int32, len(posts))
+	for i, post := range posts {
+		postIDs[i] = post.ID
+	}
+	enclosures, err := s.db.GetEnclosuresForPosts(ctx, postIDs)
+	if err != nil {
+		return fmt.Errorf("error getting enclosures: %v", err)
+	}
+	enclosuresByPost := make(map[int32][]database.Enclosure)
+	for _, enclosure := range enclosures {
+		enclosuresByPost[enclosure.PostID] = append(enclosuresByPost[enclosure.PostID], enclosure)
+	}
+	for _, post := range posts {
+		byline := ""
+		if post.Author.Valid {
+			byline = " by " + post.Author.String
+		}
+		marker := "-"
+		if !post.ReadAt.Valid {
+			marker = "*"
+		}
+		starred := ""
+		if post.StarredAt.Valid {
+			starred = " (starred)"
+		}
+		fmt.Printf("%s #%d %s%s%s (%s) %s [%s]\n", marker, post.ID, post.Title, byline, starred, post.Url, post.PublishedAt, post.FeedName)
+		if len(post.Categories) > 0 {
+			fmt.Printf("  Categories: %s\n", strings.Join(post.Categories, ", "))
+		}
+		if post.CommentsUrl.Valid {
+			fmt.Printf("  Comments: %s\n", post.CommentsUrl.String)
+		}
+		for _, enclosure := range enclosuresByPost[post.ID] {
+			fmt.Printf("  Enclosure: %s\n", describeEnclosure(enclosure))
+		}
+	}
+	return nil
+}
+
+func handlerDownload(s *state, cmd command) error {
+	args, flags, err := parseFlags(cmd.Args, []string{"limit"}, nil)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: %s <post_id|feed_name_or_url> [dir] [--limit n]", cmd.Name)
+	}
+	limit, err := intFlag(flags, "limit", 1)
+	if err != nil {
+		return err
+	}
+	dir := "."
+	if len(args) == 2 {
+		dir = args[1]
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating %s: %v", dir, err)
+	}
+
+	ctx := context.Background()
+	var enclosures []database.Enclosure
+	// A numeric argument is a post ID as shown by browse, otherwise a feed,
+	// in which case the enclosures of its latest posts are downloaded.
+	if postID, convErr := strconv.Atoi(args[0]); convErr == nil {
+		enclosures, err = s.db.GetEnclosuresForPosts(ctx, []int32{int32(postID)})
+	} else {
+		feed, feedErr := s.db.GetFeedByNameOrURL(ctx, args[0])
+		if feedErr != nil {
+			return fmt.Errorf("feed not found: %s", args[0])
+		}
+		enclosures, err = s.db.GetEnclosuresForFeed(ctx, database.GetEnclosuresForFeedParams{
+			FeedID: feed.ID,
+			Limit:  int32(limit),
+		})
+	}
+	if err != nil {
+		return fmt.Errorf("error getting enclosures: %v", err)
+	}
+	if len(enclosures) == 0 {
+		return fmt.Errorf("no enclosures found for %s", args[0])
+	}
+
+	failed := 0
+	for _, enclosure := range enclosures {
+		dest, skipped, err := downloadEnclosure(ctx, enclosure, dir)
+		switch {
+		case err != nil:
+			failed++
+			log.Printf("Error downloading %s: %v", enclosure.Url, err)
+		case skipped:
+			fmt.Printf("Already downloaded: %s\n", dest)
+		default:
+			fmt.Printf("Downloaded %s\n", dest)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d downloads failed", failed, len(enclosures))
+	}
+	return nil
+}
+
+func handlerImport(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <file.opml>", cmd.Name)
+	}
+	f, err := os.Open(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("error opening %s: %v", cmd.Args[0], err)
+	}
+	defer f.Close()
+	feeds, err := parseOPML(f)
+	if err != nil {
+		return fmt.Errorf("error reading OPML: %v", err)
+	}
+	if len(feeds) == 0 {
+		return fmt.Errorf("no feeds found in %s", cmd.Args[0])
+	}
+
+	ctx := context.Background()
+	// Feeds Gator already knows are reused as they are; only new ones are
+	// fetched to check that they work before they are added.
+	existing := make(map[string]database.Feed)
+	var newURLs []string
+	for _, feed := range feeds {
+		dbFeed, err := s.db.GetFeedByURL(ctx, feed.URL)
+		switch {
+		case err == nil:
+			existing[feed.URL] = dbFeed
+		case errors.Is(err, sql.ErrNoRows):
+			newURLs = append(newURLs, feed.URL)
+		default:
+			return fmt.Errorf("error looking up feed %s: %v", feed.URL, err)
+		}
+	}
+	if len(newURLs) > 0 {
+		fmt.Printf("Checking %d new feeds...\n", len(newURLs))
+	}
+	failures := validateFeeds(ctx, newURLs)
+
+	var created, followed, failed int
+	for _, feed := range feeds {
+		err := failures[feed.URL]
+		if err == nil {
+			var dbFeed *database.Feed
+			if f, ok := existing[feed.URL]; ok {
+				dbFeed = &f
+			}
+			var isNew, isFollowed bool
+			isNew, isFollowed, err = importOPMLFeed(ctx, s, user, feed, dbFeed)
+			if isNew {
+				created++
+			}
+			if isFollowed {
+				followed++
+			}
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("  Failed: %s (%s): %v\n", feed.Title, feed.URL, err)
+		}
+	}
+	fmt.Printf("Imported %d of %d feeds: %d new feeds, %d new follows, %d failed\n",
+		len(feeds)-failed, len(feeds), created, followed, failed)
+	return nil
+}
+
+func handlerExport(s *state, cmd command, user database.User) error {
 	if len(cmd.Args) > 1 {
-		return fmt.Errorf("usage: %s [limit]", cmd.Name)
+		return fmt.Errorf("usage: %s [file]", cmd.Name)
 	}
-	if len(cmd.Args) == 1 {
-		var err error
-		_, err = fmt.Sscanf(cmd.Args[0], "%d", &limit)
-		if err != nil || limit <= 0 {
-			return fmt.Errorf("invalid limit: %s", cmd.Args[0])
+	rows, err := s.db.GetFollowedFeedsWithFolders(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting followed feeds: %v", err)
+	}
+	feeds := make([]opmlFeed, len(rows))
+	for i, row := range rows {
+		feeds[i] = opmlFeed{Title: row.Name, URL: row.Url, Folders: row.Folders}
+	}
+	doc := buildOPML(user.Name, feeds)
+	if len(cmd.Args) == 0 {
+		return writeOPML(os.Stdout, doc)
+	}
+	f, err := os.Create(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("error creating %s: %v", cmd.Args[0], err)
+	}
+	if err := writeOPML(f, doc); err != nil {
+		f.Close()
+		return fmt.Errorf("error writing %s: %v", cmd.Args[0], err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("error writing %s: %v", cmd.Args[0], err)
+	}
+	fmt.Printf("Exported %d feeds to %s\n", len(feeds), cmd.Args[0])
+	return nil
+}
+
+func handlerRead(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <post_id>", cmd.Name)
+	}
+	ctx := context.Background()
+	post, err := followedPost(ctx, s, user, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(post.Title)
+	fmt.Printf("%s, %s\n", post.FeedName, post.PublishedAt.Format(time.RFC1123))
+	if post.Author.Valid {
+		fmt.Printf("By %s\n", post.Author.String)
+	}
+	fmt.Println(post.Url)
+	if len(post.Categories) > 0 {
+		fmt.Printf("Categories: %s\n", strings.Join(post.Categories, ", "))
+	}
+	body := post.Content.String
+	if body == "" {
+		body = post.Description.String
+	}
+	if text := htmlToText(body); text != "" {
+		fmt.Printf("\n%s\n", text)
+	}
+	enclosures, err := s.db.GetEnclosuresForPosts(ctx, []int32{post.ID})
+	if err != nil {
+		return fmt.Errorf("error getting enclosures: %v", err)
+	}
+	if len(enclosures) > 0 {
+		fmt.Println()
+	}
+	for _, enclosure := range enclosures {
+		fmt.Printf("Enclosure: %s\n", describeEnclosure(enclosure))
+	}
+	if post.CommentsUrl.Valid {
+		fmt.Printf("Comments: %s\n", post.CommentsUrl.String)
+	}
+	if post.StarredAt.Valid {
+		fmt.Printf("Starred %s\n", post.StarredAt.Time.Format(time.RFC1123))
+	}
+	err = s.db.MarkPostRead(ctx, database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error marking post read: %v", err)
+	}
+	return nil
+}
+
+// followedPost looks up a post by the ID given on the command line, as long
+// as it belongs to one of user's followed feeds.
+func followedPost(ctx context.Context, s *state, user database.User, arg string) (database.GetPostForUserRow, error) {
+	postID, err := strconv.Atoi(arg)
+	if err != nil {
+		return database.GetPostForUserRow{}, fmt.Errorf("invalid post id: %s", arg)
+	}
+	post, err := s.db.GetPostForUser(ctx, database.GetPostForUserParams{
+		ID:     int32(postID),
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return post, fmt.Errorf("post %d not found in your followed feeds", postID)
+	}
+	if err != nil {
+		return post, fmt.Errorf("error getting post: %v", err)
+	}
+	return post, nil
+}
+
+func handlerMarkRead(s *state, cmd command, user database.User) error {
+	usage := fmt.Errorf("usage: %s <feed_name_or_url> [--before date] | --before date | --all", cmd.Name)
+	args, flags, err := parseFlags(cmd.Args, []string{"before"}, []string{"all"})
+	if err != nil {
+		return err
+	}
+	// Refuse to mark everything read unless that is spelled out.
+	if len(args) > 1 || (len(args) == 0 && flags["before"] == "" && flags["all"] == "") {
+		return usage
+	}
+	if flags["all"] != "" && (len(args) > 0 || flags["before"] != "") {
+		return usage
+	}
+	ctx := context.Background()
+	params := database.MarkPostsReadParams{UserID: user.ID}
+	if len(args) == 1 {
+		feed, err := s.db.GetFeedByNameOrURL(ctx, args[0])
+		if err != nil {
+			return fmt.Errorf("feed not found: %s", args[0])
 		}
+		params.FeedID = sql.NullInt32{Int32: feed.ID, Valid: true}
+	}
+	if value, ok := flags["before"]; ok {
+		before, err := dateparse.Parse(value)
+		if err != nil {
+			return fmt.Errorf("invalid date for --before: %s", value)
+		}
+		// published_at is stored in UTC without a zone.
+		params.Before = sql.NullTime{Time: before.UTC(), Valid: true}
+	}
+	marked, err := s.db.MarkPostsRead(ctx, params)
+	if err != nil {
+		return fmt.Errorf("error marking posts read: %v", err)
+	}
+	fmt.Printf("Marked %d posts as read\n", marked)
+	return nil
+}
+
+func handlerStar(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <post_id>", cmd.Name)
 	}
 	ctx := context.Background()
-	// Get current user
-	if s.Config.CurrentUserName == "" {
-		return fmt.Errorf("not logged in")
+	post, err := followedPost(ctx, s, user, cmd.Args[0])
+	if err != nil {
+		return err
 	}
-	user, err := s.db.GetUser(ctx, s.Config.CurrentUserName)
+	err = s.db.StarPost(ctx, database.StarPostParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
 	if err != nil {
-		return fmt.Errorf("could not get user: %v", err)
+		return fmt.Errorf("error starring post: %v", err)
 	}
-	posts, err := s.db.GetPostsForUser(ctx, database.GetPostsForUserParams{
-		ID:     user.ID,
+	fmt.Printf("Starred: %s\n", post.Title)
+	return nil
+}
+
+func handlerUnstar(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 1 {
+		return fmt.Errorf("usage: %s <post_id>", cmd.Name)
+	}
+	postID, err := strconv.Atoi(cmd.Args[0])
+	if err != nil {
+		return fmt.Errorf("invalid post id: %s", cmd.Args[0])
+	}
+	n, err := s.db.UnstarPost(context.Background(), database.UnstarPostParams{
+		UserID: user.ID,
+		PostID: int32(postID),
+	})
+	if err != nil {
+		return fmt.Errorf("error unstarring post: %v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("post %d is not starred", postID)
+	}
+	fmt.Printf("Unstarred post %d\n", postID)
+	return nil
+}
+
+func handlerStarred(s *state, cmd command, user database.User) error {
+	limit := 20
+	if len(cmd.Args) > 1 {
+		return fmt.Errorf("usage: %s [limit]", cmd.Name)
+	}
+	if len(cmd.Args) == 1 {
+		n, err := strconv.Atoi(cmd.Args[0])
+		if err != nil || n <= 0 {
+			return fmt.Errorf("invalid limit: %s", cmd.Args[0])
+		}
+		limit = n
+	}
+	posts, err := s.db.GetStarredPostsForUser(context.Background(), database.GetStarredPostsForUserParams{
+		UserID: user.ID,
 		Limit:  int32(limit),
 		Offset: 0,
 	})
 	if err != nil {
-		return fmt.Errorf("error getting posts: %v", err)
+		return fmt.Errorf("error getting starred posts: %v", err)
 	}
 	if len(posts) == 0 {
-		fmt.Println("No posts found.")
+		fmt.Println("No starred posts.")
 		return nil
 	}
 	for _, post := range posts {
-		fmt.Printf("- %s (%s) %s\n", post.Title, post.Url, post.PublishedAt)
+		fmt.Printf("- #%d %s (%s) %s [%s]\n", post.ID, post.Title, post.Url, post.PublishedAt, post.FeedName)
+	}
+	return nil
+}
+
+func handlerSearch(s *state, cmd command, user database.User) error {
+	args, flags, err := parseFlags(cmd.Args, []string{"limit", "folder"}, nil)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return fmt.Errorf("usage: %s <query> [--limit n] [--folder name]", cmd.Name)
+	}
+	limit, err := intFlag(flags, "limit", 10)
+	if err != nil {
+		return err
+	}
+	folder, err := folderFlag(flags)
+	if err != nil {
+		return err
+	}
+	query := strings.Join(args, " ")
+	results, err := s.db.SearchPosts(context.Background(), database.SearchPostsParams{
+		Query:  query,
+		UserID: user.ID,
+		Folder: folder,
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return fmt.Errorf("error searching posts: %v", err)
+	}
+	if len(results) == 0 {
+		fmt.Printf("No posts match %q.\n", query)
+		return nil
+	}
+	for _, result := range results {
+		fmt.Printf("- #%d %s [%s] %s (score %.2f)\n", result.ID, result.Title, result.FeedName, result.PublishedAt.Format("2006-01-02"), result.Rank)
+		fmt.Printf("  %s\n", result.Url)
+		// The snippet is cut from the post's HTML, so strip what markup
+		// survives; matches are wrapped in ** by the query.
+		if snippet := strings.Join(strings.Fields(htmlToText(result.Snippet)), " "); snippet != "" {
+			fmt.Printf("  %s\n", snippet)
+		}
 	}
 	return nil
 }
 
-func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-		Timeout: 3 * time.Second,
+// cleanFolderName normalizes a folder path such as " Tech / Go/" to
+// "Tech/Go". Nested folders are written with "/" like OPML outlines.
+func cleanFolderName(name string) (string, error) {
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	if len(parts) == 0 {
+		return "", fmt.Errorf("invalid folder name: %q", name)
+	}
+	return strings.Join(parts, "/"), nil
+}
+
+// folderFlag returns the --folder filter, which also matches the folder's
+// subfolders.
+func folderFlag(flags map[string]string) (sql.NullString, error) {
+	value, ok := flags["folder"]
+	if !ok {
+		return sql.NullString{}, nil
+	}
+	folder, err := cleanFolderName(value)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: folder, Valid: true}, nil
+}
+
+// userFeedFollow finds user's follow of the feed named by name or URL.
+func userFeedFollow(ctx context.Context, s *state, user database.User, nameOrURL string) (database.FeedFollow, error) {
+	feed, err := s.db.GetFeedByNameOrURL(ctx, nameOrURL)
+	if err != nil {
+		return database.FeedFollow{}, fmt.Errorf("feed not found: %s", nameOrURL)
+	}
+	follow, err := s.db.GetFeedFollow(ctx, database.GetFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feed.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return follow, fmt.Errorf("you don't follow %s", feed.Name)
+	}
+	if err != nil {
+		return follow, fmt.Errorf("error getting feed follow: %v", err)
+	}
+	return follow, nil
+}
+
+func handlerTag(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("usage: %s <feed_name_or_url> <folder>", cmd.Name)
 	}
-	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	folder, err := cleanFolderName(cmd.Args[1])
 	if err != nil {
-		return nil, fmt.Errorf("error creating request: %v", err)
+		return err
 	}
-	req.Header.Set("User-Agent", "Gator/1.0")
-	resp, err := client.Do(req)
+	ctx := context.Background()
+	follow, err := userFeedFollow(ctx, s, user, cmd.Args[0])
 	if err != nil {
-		return nil, fmt.Errorf("error fetching feed: %v", err)
+		return err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	folderID, err := s.db.UpsertFolder(ctx, database.UpsertFolderParams{
+		UserID: user.ID,
+		Name:   folder,
+	})
 	if err != nil {
-		return nil, fmt.Errorf("error reading response body: %v", err)
+		return fmt.Errorf("error creating folder: %v", err)
 	}
-	var feed RSSFeed
-	if err := xml.Unmarshal(body, &feed); err != nil {
-		return nil, fmt.Errorf("error unmarshalling XML: %v", err)
+	err = s.db.AddFeedFollowToFolder(ctx, database.AddFeedFollowToFolderParams{
+		FeedFollowID: follow.ID,
+		FolderID:     folderID,
+	})
+	if err != nil {
+		return fmt.Errorf("error adding feed to folder: %v", err)
 	}
-	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
-	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
-	return &feed, nil
+	fmt.Printf("Added %s to %s\n", cmd.Args[0], folder)
+	return nil
+}
+
+func handlerUntag(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 2 {
+		return fmt.Errorf("usage: %s <feed_name_or_url> <folder>", cmd.Name)
+	}
+	folder, err := cleanFolderName(cmd.Args[1])
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	follow, err := userFeedFollow(ctx, s, user, cmd.Args[0])
+	if err != nil {
+		return err
+	}
+	n, err := s.db.RemoveFeedFollowFromFolder(ctx, database.RemoveFeedFollowFromFolderParams{
+		FeedFollowID: follow.ID,
+		UserID:       user.ID,
+		Name:         folder,
+	})
+	if err != nil {
+		return fmt.Errorf("error removing feed from folder: %v", err)
+	}
+	if n == 0 {
+		return fmt.Errorf("%s is not in %s", cmd.Args[0], folder)
+	}
+	if err := s.db.DeleteEmptyFolders(ctx, user.ID); err != nil {
+		return fmt.Errorf("error removing empty folders: %v", err)
+	}
+	fmt.Printf("Removed %s from %s\n", cmd.Args[0], folder)
+	return nil
+}
+
+func handlerFolders(s *state, cmd command, user database.User) error {
+	if len(cmd.Args) != 0 {
+		return fmt.Errorf("usage: %s", cmd.Name)
+	}
+	folders, err := s.db.GetFoldersForUser(context.Background(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting folders: %v", err)
+	}
+	if len(folders) == 0 {
+		fmt.Println("No folders.")
+		return nil
+	}
+	for _, folder := range folders {
+		fmt.Printf("- %s (%d feeds)\n", folder.Name, folder.FeedCount)
+	}
+	return nil
 }
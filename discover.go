@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlPageError is returned when a URL given as a feed serves an HTML page,
+// typically a site's home page, so the caller can look for its feeds.
+type htmlPageError struct {
+	URL  string
+	Body []byte
+}
+
+func (e *htmlPageError) Error() string {
+	return fmt.Sprintf("%s is an HTML page, not a feed", e.URL)
+}
+
+// isHTMLPage reports whether body is an HTML or XHTML document.
+func isHTMLPage(body []byte) bool {
+	if strings.HasPrefix(http.DetectContentType(body), "text/html") {
+		return true
+	}
+	root, err := rootElement(body)
+	return err == nil && strings.EqualFold(root.Local, "html")
+}
+
+// feedLinkTypes are the link types that mark a feed in an HTML page's
+// <link rel="alternate"> elements.
+var feedLinkTypes = map[string]bool{
+	"application/rss+xml":   true,
+	"application/atom+xml":  true,
+	"application/feed+json": true,
+	"application/rdf+xml":   true,
+}
+
+// commonFeedPaths are tried on the page's site when the page doesn't
+// advertise any feeds itself.
+var commonFeedPaths = []string{"/feed", "/rss", "/rss.xml", "/atom.xml", "/feed.xml", "/index.xml"}
+
+// feedCandidate is a feed found for a web page.
+type feedCandidate struct {
+	URL   string
+	Title string
+}
+
+// discoverFeeds finds the feeds of the HTML page at pageURL: the ones it
+// links with <link rel="alternate">, or failing that, whichever of the
+// common feed paths on its site serve a feed. Every candidate is fetched
+// and only those that parse as feeds are returned.
+func discoverFeeds(ctx context.Context, pageURL string, body []byte) ([]feedCandidate, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid page URL %s: %v", pageURL, err)
+	}
+	candidates := feedLinks(base, body)
+	if len(candidates) == 0 {
+		for _, p := range commonFeedPaths {
+			candidates = append(candidates, feedCandidate{URL: base.ResolveReference(&url.URL{Path: p}).String()})
+		}
+	}
+
+	// Check the candidates in parallel, keeping them in page order.
+	valid := make([]bool, len(candidates))
+	var wg sync.WaitGroup
+	for i := range candidates {
+		wg.Add(1)
+		go func(c *feedCandidate, ok *bool) {
+			defer wg.Done()
+			feed, err := fetchFeed(ctx, c.URL)
+			if err != nil {
+				return
+			}
+			if c.Title == "" {
+				c.Title = feed.Channel.Title
+			}
+			*ok = true
+		}(&candidates[i], &valid[i])
+	}
+	wg.Wait()
+
+	var found []feedCandidate
+	for i, c := range candidates {
+		if valid[i] {
+			found = append(found, c)
+		}
+	}
+	return found, nil
+}
+
+// feedLinks returns the feeds an HTML page advertises, resolved against the
+// page URL or the page's <base href>, without duplicates.
+func feedLinks(base *url.URL, body []byte) []feedCandidate {
+	var links []feedCandidate
+	seen := make(map[string]bool)
+	z := html.NewTokenizer(bytes.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return links
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+		name, hasAttr := z.TagName()
+		tag := atom.Lookup(name)
+		if (tag != atom.Link && tag != atom.Base) || !hasAttr {
+			continue
+		}
+		attrs := make(map[string]string)
+		for {
+			key, val, more := z.TagAttr()
+			attrs[string(key)] = strings.TrimSpace(string(val))
+			if !more {
+				break
+			}
+		}
+		if tag == atom.Base {
+			if href, err := base.Parse(attrs["href"]); err == nil && attrs["href"] != "" {
+				base = href
+			}
+			continue
+		}
+		if !hasToken(attrs["rel"], "alternate") || !feedLinkTypes[strings.ToLower(attrs["type"])] || attrs["href"] == "" {
+			continue
+		}
+		href, err := base.Parse(attrs["href"])
+		if err != nil || seen[href.String()] {
+			continue
+		}
+		seen[href.String()] = true
+		links = append(links, feedCandidate{URL: href.String(), Title: attrs["title"]})
+	}
+}
+
+// hasToken reports whether the space-separated list contains token,
+// ignoring case, as in rel="alternate home".
+func hasToken(list, token string) bool {
+	for _, field := range strings.Fields(list) {
+		if strings.EqualFold(field, token) {
+			return true
+		}
+	}
+	return false
+}
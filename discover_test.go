@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestFeedLinks(t *testing.T) {
+	tests := []struct {
+		name, page string
+		want       []feedCandidate
+	}{
+		{
+			"relative and absolute links",
+			`<html><head>
+			<link rel="alternate" type="application/rss+xml" title="Posts" href="/feed.xml">
+			<link rel="Alternate Home" type="Application/Atom+XML" href="https://other.example/atom">
+			<link rel="alternate" type="application/feed+json" href="feed.json"/>
+			</head></html>`,
+			[]feedCandidate{
+				{URL: "https://example.com/feed.xml", Title: "Posts"},
+				{URL: "https://other.example/atom"},
+				{URL: "https://example.com/blog/feed.json"},
+			},
+		},
+		{
+			"base href",
+			`<head><base href="https://cdn.example/site/"><link rel="alternate" type="application/rdf+xml" href="index.rdf"></head>`,
+			[]feedCandidate{{URL: "https://cdn.example/site/index.rdf"}},
+		},
+		{
+			"duplicates",
+			`<link rel="alternate" type="application/rss+xml" href="/rss"><link rel="alternate" type="application/rss+xml" href="https://example.com/rss">`,
+			[]feedCandidate{{URL: "https://example.com/rss"}},
+		},
+		{
+			"not feeds",
+			`<link rel="stylesheet" type="text/css" href="/style.css">
+			<link rel="alternate" type="text/html" hreflang="fr" href="/fr/">
+			<link rel="alternate" type="application/rss+xml">
+			<a rel="alternate" type="application/rss+xml" href="/rss">`,
+			nil,
+		},
+	}
+	base, _ := url.Parse("https://example.com/blog/")
+	for _, tt := range tests {
+		got := feedLinks(base, []byte(tt.page))
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsHTMLPage(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{`<!DOCTYPE html><html><body></body></html>`, true},
+		{`<?xml version="1.0"?><html xmlns="http://www.w3.org/1999/xhtml"><body/></html>`, true},
+		{rssFixture, false},
+		{atomFixture, false},
+		{jsonFeedFixture, false},
+	}
+	for _, tt := range tests {
+		if got := isHTMLPage([]byte(tt.body)); got != tt.want {
+			t.Errorf("isHTMLPage(%.40q) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestDiscoverFeeds(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rss.xml", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(rssFixture))
+	})
+	mux.HandleFunc("/atom", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/atom+xml")
+		w.Write([]byte(atomFixture))
+	})
+	mux.HandleFunc("/page.html", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<html></html>`))
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		name, page string
+		want       []feedCandidate
+	}{
+		{
+			"advertised feeds in page order",
+			`<link rel="alternate" type="application/atom+xml" href="/atom">
+			<link rel="alternate" type="application/rss+xml" title="Missing" href="/missing">
+			<link rel="alternate" type="application/rss+xml" title="Not a feed" href="/page.html">
+			<link rel="alternate" type="application/rss+xml" href="/rss.xml">`,
+			[]feedCandidate{
+				{URL: srv.URL + "/atom", Title: "Tom & Jerry"},
+				{URL: srv.URL + "/rss.xml", Title: "Example"},
+			},
+		},
+		{
+			"common paths when nothing is advertised",
+			`<html><head><title>Home</title></head></html>`,
+			[]feedCandidate{{URL: srv.URL + "/rss.xml", Title: "Example"}},
+		},
+	}
+	for _, tt := range tests {
+		got, err := discoverFeeds(context.Background(), srv.URL+"/", []byte(tt.page))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Specter242/Gator/internal/database"
+)
+
+// MediaContent is a Media RSS <media:content> element, used by podcast and
+// video feeds alongside or instead of <enclosure>.
+type MediaContent struct {
+	URL      string `xml:"url,attr"`
+	Type     string `xml:"type,attr"`
+	FileSize string `xml:"fileSize,attr"`
+	Duration string `xml:"duration,attr"`
+}
+
+// MediaGroup is a <media:group> of alternative renditions of one item.
+type MediaGroup struct {
+	Content []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+}
+
+// mergeEnclosures folds media:content elements into Enclosures, dropping
+// URLs already listed, and gives the itunes:duration to the first
+// enclosure, the episode it describes, unless it already has one.
+func (item *RSSItem) mergeEnclosures() {
+	media := item.MediaContent
+	for _, group := range item.MediaGroups {
+		media = append(media, group.Content...)
+	}
+	seen := make(map[string]int)
+	var enclosures []RSSEnclosure
+	for _, enclosure := range item.Enclosures {
+		enclosure.URL = strings.TrimSpace(enclosure.URL)
+		if enclosure.URL == "" {
+			continue
+		}
+		if _, ok := seen[enclosure.URL]; ok {
+			continue
+		}
+		seen[enclosure.URL] = len(enclosures)
+		enclosures = append(enclosures, enclosure)
+	}
+	for _, mc := range media {
+		mc.URL = strings.TrimSpace(mc.URL)
+		if mc.URL == "" {
+			continue
+		}
+		if i, ok := seen[mc.URL]; ok {
+			// The same file as an <enclosure>; keep whatever it adds.
+			if enclosures[i].Type == "" {
+				enclosures[i].Type = mc.Type
+			}
+			if enclosures[i].Length == "" {
+				enclosures[i].Length = mc.FileSize
+			}
+			if enclosures[i].Duration == "" {
+				enclosures[i].Duration = mc.Duration
+			}
+			continue
+		}
+		seen[mc.URL] = len(enclosures)
+		enclosures = append(enclosures, RSSEnclosure{
+			URL:      mc.URL,
+			Type:     mc.Type,
+			Length:   mc.FileSize,
+			Duration: mc.Duration,
+		})
+	}
+	if len(enclosures) > 0 && enclosures[0].Duration == "" {
+		enclosures[0].Duration = strings.TrimSpace(item.ITunesDuration)
+	}
+	item.Enclosures = enclosures
+}
+
+// parseDuration reads an itunes:duration or media:content duration: plain
+// seconds (possibly fractional), MM:SS or HH:MM:SS.
+func parseDuration(value string) (int, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, false
+	}
+	total := 0.0
+	for i, part := range parts {
+		n, err := strconv.ParseFloat(part, 64)
+		if err != nil || n < 0 {
+			return 0, false
+		}
+		// Only the last field may be fractional.
+		if i < len(parts)-1 && n != math.Trunc(n) {
+			return 0, false
+		}
+		total = total*60 + n
+	}
+	if total > math.MaxInt32 {
+		return 0, false
+	}
+	return int(math.Round(total)), true
+}
+
+// storeEnclosures saves item's enclosures against the post they belong to.
+func storeEnclosures(ctx context.Context, s *state, postID int32, item RSSItem) error {
+	for _, enclosure := range item.Enclosures {
+		var length sql.NullInt64
+		if n, err := strconv.ParseInt(strings.TrimSpace(enclosure.Length), 10, 64); err == nil && n > 0 {
+			length = sql.NullInt64{Int64: n, Valid: true}
+		}
+		var duration sql.NullInt32
+		if seconds, ok := parseDuration(enclosure.Duration); ok {
+			duration = sql.NullInt32{Int32: int32(seconds), Valid: true}
+		}
+		err := s.db.UpsertEnclosure(ctx, database.UpsertEnclosureParams{
+			PostID:          postID,
+			Url:             enclosure.URL,
+			MimeType:        sql.NullString{String: enclosure.Type, Valid: enclosure.Type != ""},
+			Length:          length,
+			DurationSeconds: duration,
+		})
+		if err != nil {
+			return fmt.Errorf("error saving enclosure %s: %v", enclosure.URL, err)
+		}
+	}
+	return nil
+}
+
+// describeEnclosure summarizes an enclosure for display, as in
+// "https://example.com/ep1.mp3 (audio/mpeg, 24.1 MB, 1h2m3s)".
+func describeEnclosure(enclosure database.Enclosure) string {
+	var details []string
+	if enclosure.MimeType.Valid {
+		details = append(details, enclosure.MimeType.String)
+	}
+	if enclosure.Length.Valid {
+		details = append(details, formatBytes(enclosure.Length.Int64))
+	}
+	if enclosure.DurationSeconds.Valid {
+		details = append(details, (time.Duration(enclosure.DurationSeconds.Int32) * time.Second).String())
+	}
+	if len(details) == 0 {
+		return enclosure.Url
+	}
+	return fmt.Sprintf("%s (%s)", enclosure.Url, strings.Join(details, ", "))
+}
+
+func formatBytes(n int64) string {
+	const unit = 1000
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "kMGTPE"[exp])
+}
+
+// enclosureFileName picks a local name for an enclosure: the last segment
+// of its URL path prefixed with the post ID, since many hosts serve every
+// episode under the same name, or a name derived from the post alone when
+// the URL path has none.
+func enclosureFileName(enclosure database.Enclosure) string {
+	if u, err := url.Parse(enclosure.Url); err == nil {
+		if name := path.Base(u.Path); name != "." && name != "/" && name != "" {
+			return fmt.Sprintf("%d-%s", enclosure.PostID, name)
+		}
+	}
+	return fmt.Sprintf("%d-enclosure-%d", enclosure.PostID, enclosure.ID)
+}
+
+// downloadEnclosure saves enclosure into dir and returns the file's path.
+// Data is written to a .part file that is renamed once complete; if one is
+// left over from an interrupted download the transfer resumes from where it
+// stopped, provided the server supports range requests. Files already
+// downloaded are left alone and reported with skipped set.
+func downloadEnclosure(ctx context.Context, enclosure database.Enclosure, dir string) (string, bool, error) {
+	dest := filepath.Join(dir, enclosureFileName(enclosure))
+	if _, err := os.Stat(dest); err == nil {
+		return dest, true, nil
+	}
+	partial := dest + ".part"
+	var offset int64
+	if info, err := os.Stat(partial); err == nil {
+		offset = info.Size()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", enclosure.Url, nil)
+	if err != nil {
+		return dest, false, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("User-Agent", "Gator/1.0")
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return dest, false, fmt.Errorf("error downloading: %v", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// No range support, or nothing to resume: start over.
+		flags |= os.O_TRUNC
+	case http.StatusRequestedRangeNotSatisfiable:
+		// The partial file already holds everything the server has.
+		if offset > 0 {
+			return dest, false, os.Rename(partial, dest)
+		}
+		fallthrough
+	default:
+		return dest, false, fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	f, err := os.OpenFile(partial, flags, 0o644)
+	if err != nil {
+		return dest, false, err
+	}
+	_, err = io.Copy(f, resp.Body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return dest, false, fmt.Errorf("download interrupted, run again to resume: %v", err)
+	}
+	if err := os.Rename(partial, dest); err != nil {
+		return dest, false, err
+	}
+	return dest, false, nil
+}
@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
+)
+
+// RSSFeed is the normalized feed model. RSS 2.0 documents unmarshal into it
+// directly; every other supported format is converted into it by its parser.
+type RSSFeed struct {
+	Channel RSSChannel `xml:"channel"`
+}
+
+type RSSChannel struct {
+	Title         string    `xml:"-"`
+	Link          string    `xml:"-"`
+	Description   string    `xml:"-"`
+	PubDate       string    `xml:"pubDate"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Item          []RSSItem `xml:"item"`
+	RSSScheduleHints
+	// A tag without a namespace matches the local name in any namespace,
+	// so media:title or atom:link would overwrite the core fields. These
+	// collect every match and normalize picks out the core element.
+	TitleElements       []xmlText `xml:"title"`
+	LinkElements        []xmlText `xml:"link"`
+	DescriptionElements []xmlText `xml:"description"`
+}
+
+// normalize fills the core fields from the decoded elements and normalizes
+// each item.
+func (c *RSSChannel) normalize() {
+	c.Title = coreText(c.TitleElements)
+	c.Link = coreText(c.LinkElements)
+	c.Description = coreText(c.DescriptionElements)
+	for i := range c.Item {
+		c.Item[i].normalize()
+	}
+}
+
+// xmlText is an element's name and text, so elements sharing a local name
+// can be told apart by namespace.
+type xmlText struct {
+	XMLName xml.Name
+	Text    string `xml:",chardata"`
+}
+
+// rssNamespaces are the namespaces core RSS elements appear in: none for RSS
+// 2.0, the RSS 1.0 namespace RDF documents declare as the default, and the
+// namespace a few RSS 2.0 feeds declare.
+var rssNamespaces = map[string]bool{
+	"":                                 true,
+	"http://purl.org/rss/1.0/":         true,
+	"http://backend.userland.com/rss2": true,
+}
+
+// coreText returns the text of the first core RSS element among elements,
+// ignoring extension elements such as media:title or itunes:author.
+func coreText(elements []xmlText) string {
+	for _, element := range elements {
+		if rssNamespaces[element.XMLName.Space] {
+			return element.Text
+		}
+	}
+	return ""
+}
+
+// coreTexts returns the text of every core RSS element among elements.
+func coreTexts(elements []xmlText) []string {
+	var texts []string
+	for _, element := range elements {
+		if rssNamespaces[element.XMLName.Space] {
+			texts = append(texts, element.Text)
+		}
+	}
+	return texts
+}
+
+// RSSScheduleHints are the publisher's polling hints: the RSS 2.0 ttl and
+// skip elements and the RSS 1.0 syndication module.
+type RSSScheduleHints struct {
+	TTL             string   `xml:"ttl"`
+	SkipHours       []string `xml:"skipHours>hour"`
+	SkipDays        []string `xml:"skipDays>day"`
+	UpdatePeriod    string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updatePeriod"`
+	UpdateFrequency string   `xml:"http://purl.org/rss/1.0/modules/syndication/ updateFrequency"`
+}
+
+type RSSItem struct {
+	Title          string         `xml:"-"`
+	Link           string         `xml:"-"`
+	Description    string         `xml:"-"`
+	Content        string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PubDate        string         `xml:"pubDate"`
+	GUID           string         `xml:"guid"`
+	Author         string         `xml:"-"`
+	Categories     []string       `xml:"-"`
+	Enclosures     []RSSEnclosure `xml:"enclosure"`
+	DCDate         string         `xml:"http://purl.org/dc/elements/1.1/ date"`
+	DCCreator      string         `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	DCSubjects     []string       `xml:"http://purl.org/dc/elements/1.1/ subject"`
+	MediaContent   []MediaContent `xml:"http://search.yahoo.com/mrss/ content"`
+	MediaGroups    []MediaGroup   `xml:"http://search.yahoo.com/mrss/ group"`
+	ITunesDuration string         `xml:"http://www.itunes.com/dtds/podcast-1.0.dtd duration"`
+	// Comments collects every <comments> element, since the tag also
+	// matches slash:comments, which holds a count rather than a link.
+	// normalize picks the link out into CommentsURL.
+	Comments    []string `xml:"comments"`
+	CommentsURL string   `xml:"-"`
+	// As on RSSChannel, these collect title, link, description, author and
+	// category elements in any namespace for normalize to pick from.
+	TitleElements       []xmlText `xml:"title"`
+	LinkElements        []xmlText `xml:"link"`
+	DescriptionElements []xmlText `xml:"description"`
+	AuthorElements      []xmlText `xml:"author"`
+	CategoryElements    []xmlText `xml:"category"`
+}
+
+// identity returns a stable key for the item within its feed: the
+// publisher's guid, falling back to the link, falling back to a hash of the
+// item's content.
+func (item RSSItem) identity() string {
+	if guid := strings.TrimSpace(item.GUID); guid != "" {
+		return guid
+	}
+	if link := strings.TrimSpace(item.Link); link != "" {
+		return link
+	}
+	sum := sha256.Sum256([]byte(item.Title + "\x00" + item.Description + "\x00" + item.PubDate))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// normalize fills the core fields from the decoded core elements, or from
+// their Dublin Core equivalents when a feed only provides the latter, and
+// tidies the author and categories.
+func (item *RSSItem) normalize() {
+	item.Title = coreText(item.TitleElements)
+	item.Link = coreText(item.LinkElements)
+	item.Description = coreText(item.DescriptionElements)
+	item.Author = coreText(item.AuthorElements)
+	if item.PubDate == "" {
+		item.PubDate = item.DCDate
+	}
+	if item.Author == "" {
+		item.Author = item.DCCreator
+	}
+	item.Author = authorName(item.Author)
+	item.mergeEnclosures()
+	item.Categories = uniqueCategories(append(coreTexts(item.CategoryElements), item.DCSubjects...))
+	for _, comments := range item.Comments {
+		comments = strings.TrimSpace(comments)
+		if strings.HasPrefix(comments, "http://") || strings.HasPrefix(comments, "https://") {
+			item.CommentsURL = comments
+			break
+		}
+	}
+}
+
+// rssAuthor matches the RSS 2.0 author form, an email address followed by
+// the name in parentheses.
+var rssAuthor = regexp.MustCompile(`^\S+@\S+\s*\((.+)\)$`)
+
+// authorName reduces "jo@example.com (Jo Bloggs)" to "Jo Bloggs" and leaves
+// anything else as it is.
+func authorName(author string) string {
+	author = strings.TrimSpace(author)
+	if m := rssAuthor.FindStringSubmatch(author); m != nil {
+		return strings.TrimSpace(m[1])
+	}
+	return author
+}
+
+// uniqueCategories trims categories and drops empty and repeated ones,
+// comparing case-insensitively and keeping the first spelling seen. The
+// result is never nil, as posts.categories is NOT NULL.
+func uniqueCategories(categories []string) []string {
+	unique := []string{}
+	seen := make(map[string]bool)
+	for _, category := range categories {
+		category = strings.TrimSpace(html.UnescapeString(category))
+		key := strings.ToLower(category)
+		if category == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, category)
+	}
+	return unique
+}
+
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+	// Duration comes from itunes:duration or media:content rather than the
+	// enclosure element itself.
+	Duration string `xml:"-"`
+}
+
+// feedAcceptHeader advertises every format parseFeed understands.
+const feedAcceptHeader = "application/rss+xml, application/atom+xml, application/feed+json, application/xml;q=0.9, */*;q=0.8"
+
+// feedValidators are the HTTP cache validators a server returned for a feed,
+// replayed on the next fetch so unchanged feeds cost a 304.
+type feedValidators struct {
+	ETag         string
+	LastModified string
+}
+
+type fetchResult struct {
+	Feed        *RSSFeed
+	NotModified bool
+	StatusCode  int
+	Validators  feedValidators
+	// FinalURL is where the feed was served from after following redirects.
+	// MovedPermanently is set when every redirect on the way was a 301 or 308.
+	FinalURL         string
+	MovedPermanently bool
+}
+
+// maxRedirects is how many redirect hops fetchFeed follows.
+const maxRedirects = 5
+
+// statusError is returned for responses that are neither 200 nor 304.
+type statusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("unexpected status fetching feed: %s", e.Status)
+}
+
+func fetchFeed(ctx context.Context, feedURL string) (*RSSFeed, error) {
+	result, err := fetchFeedConditional(ctx, feedURL, feedValidators{})
+	if err != nil {
+		return nil, err
+	}
+	return result.Feed, nil
+}
+
+// fetchFeedConditional fetches feedURL, sending If-None-Match and
+// If-Modified-Since from validators. A 304 response yields a result with
+// NotModified set and no Feed.
+func fetchFeedConditional(ctx context.Context, feedURL string, validators feedValidators) (*fetchResult, error) {
+	// A move needs at least one redirect, and every hop to be permanent.
+	redirected, permanent := false, true
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			redirected = true
+			switch req.Response.StatusCode {
+			case http.StatusMovedPermanently, http.StatusPermanentRedirect:
+			default:
+				permanent = false
+			}
+			return nil
+		},
+		Timeout: 3 * time.Second,
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", feedURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", err)
+	}
+	req.Header.Set("User-Agent", "Gator/1.0")
+	req.Header.Set("Accept", feedAcceptHeader)
+	if validators.ETag != "" {
+		req.Header.Set("If-None-Match", validators.ETag)
+	}
+	if validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", validators.LastModified)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching feed: %v", err)
+	}
+	defer resp.Body.Close()
+	finalURL := resp.Request.URL.String()
+	moved := redirected && permanent && finalURL != feedURL
+	if resp.StatusCode == http.StatusNotModified {
+		return &fetchResult{
+			NotModified:      true,
+			StatusCode:       resp.StatusCode,
+			Validators:       validators,
+			FinalURL:         finalURL,
+			MovedPermanently: moved,
+		}, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &statusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %v", err)
+	}
+	feed, err := parseFeed(resp.Header.Get("Content-Type"), body)
+	if err != nil {
+		if isHTMLPage(body) {
+			return nil, &htmlPageError{URL: finalURL, Body: body}
+		}
+		return nil, err
+	}
+	return &fetchResult{
+		Feed:       feed,
+		StatusCode: resp.StatusCode,
+		Validators: feedValidators{
+			ETag:         resp.Header.Get("ETag"),
+			LastModified: resp.Header.Get("Last-Modified"),
+		},
+		FinalURL:         finalURL,
+		MovedPermanently: moved,
+	}, nil
+}
+
+// parseFeed sniffs the format of body from the response content type and
+// the document itself and decodes it into an RSSFeed.
+func parseFeed(contentType string, body []byte) (*RSSFeed, error) {
+	body, err := toUTF8(contentType, body)
+	if err != nil {
+		return nil, err
+	}
+	if isJSONFeed(contentType, body) {
+		feed, err := parseJSONFeed(body)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling JSON Feed: %v", err)
+		}
+		return feed, nil
+	}
+	root, err := rootElement(body)
+	if err != nil {
+		return nil, fmt.Errorf("error unmarshalling XML: %v", err)
+	}
+	var feed *RSSFeed
+	switch root.Local {
+	case "rss":
+		feed = &RSSFeed{}
+		if err := newXMLDecoder(body).Decode(feed); err != nil {
+			return nil, fmt.Errorf("error unmarshalling XML: %v", err)
+		}
+		feed.Channel.normalize()
+	case "RDF":
+		feed, err = parseRDF(body)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling RDF: %v", err)
+		}
+	case "feed":
+		feed, err = parseAtom(body)
+		if err != nil {
+			return nil, fmt.Errorf("error unmarshalling Atom: %v", err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported feed format: <%s>", root.Local)
+	}
+	feed.Channel.Title = html.UnescapeString(feed.Channel.Title)
+	feed.Channel.Description = html.UnescapeString(feed.Channel.Description)
+	return feed, nil
+}
+
+// rootElement returns the name of the first element in an XML document.
+func rootElement(body []byte) (xml.Name, error) {
+	decoder := newXMLDecoder(body)
+	for {
+		tok, err := decoder.Token()
+		if err != nil {
+			return xml.Name{}, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			return start.Name, nil
+		}
+	}
+}
+
+// newXMLDecoder returns a decoder for body that transcodes any encoding
+// named in the XML declaration, such as ISO-8859-1 or windows-1251, to UTF-8.
+func newXMLDecoder(body []byte) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	decoder.CharsetReader = charset.NewReaderLabel
+	return decoder
+}
+
+var xmlEncodingDecl = regexp.MustCompile(`^(<\?xml[^>]*?encoding=)["'][^"']*["']`)
+
+// toUTF8 applies the charset from the Content-Type header. When the header
+// names a charset it wins over the XML declaration, so the body is
+// transcoded to UTF-8 and the declaration rewritten to match. The exception
+// is a header claiming UTF-8 for a body that isn't, a common server
+// misconfiguration, where the declaration is trusted instead.
+func toUTF8(contentType string, body []byte) ([]byte, error) {
+	body = bytes.TrimPrefix(body, []byte("\xef\xbb\xbf"))
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil || params["charset"] == "" {
+		return body, nil
+	}
+	enc, name := charset.Lookup(params["charset"])
+	if enc == nil {
+		return nil, fmt.Errorf("unsupported charset %q", params["charset"])
+	}
+	if name == "utf-8" {
+		if !utf8.Valid(body) {
+			return body, nil
+		}
+	} else {
+		body, err = enc.NewDecoder().Bytes(body)
+		if err != nil {
+			return nil, fmt.Errorf("error decoding %s body: %v", name, err)
+		}
+	}
+	return xmlEncodingDecl.ReplaceAll(body, []byte(`${1}"UTF-8"`)), nil
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+)
+
+const rssFixture = `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Example</title><link>https://example.com/</link></channel></rss>`
+
+func TestFetchFeedRedirects(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/feed", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/rss+xml")
+		w.Write([]byte(rssFixture))
+	})
+	mux.Handle("/moved", http.RedirectHandler("/feed", http.StatusMovedPermanently))
+	mux.Handle("/moved-twice", http.RedirectHandler("/moved", http.StatusPermanentRedirect))
+	mux.Handle("/temporary", http.RedirectHandler("/feed", http.StatusFound))
+	mux.Handle("/mixed", http.RedirectHandler("/temporary", http.StatusMovedPermanently))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tests := []struct {
+		path  string
+		moved bool
+	}{
+		{"/feed", false},
+		{"/feed?", false},
+		{"/moved", true},
+		{"/moved-twice", true},
+		{"/temporary", false},
+		{"/mixed", false},
+	}
+	for _, tt := range tests {
+		result, err := fetchFeedConditional(context.Background(), srv.URL+tt.path, feedValidators{})
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		if result.MovedPermanently != tt.moved {
+			t.Errorf("%s: MovedPermanently = %v, want %v", tt.path, result.MovedPermanently, tt.moved)
+		}
+		if result.FinalURL != srv.URL+"/feed" && tt.moved {
+			t.Errorf("%s: FinalURL = %s", tt.path, result.FinalURL)
+		}
+	}
+}
+
+func TestParseFeedCharsets(t *testing.T) {
+	rss := func(decl, title string) []byte {
+		return []byte(`<?xml version="1.0"` + decl + `?><rss version="2.0"><channel><title>` + title + `</title></channel></rss>`)
+	}
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        string
+	}{
+		{"utf-8", "application/rss+xml; charset=utf-8", rss("", "Café"), "Café"},
+		{"header only", "application/rss+xml; charset=ISO-8859-1", rss("", "Caf\xe9"), "Café"},
+		{"header wins over declaration", "text/xml; charset=windows-1251", rss(` encoding="utf-8"`, "\xcf\xf0\xe8\xe2\xe5\xf2"), "Привет"},
+		{"declaration only", "application/rss+xml", rss(` encoding="windows-1251"`, "\xcf\xf0\xe8\xe2\xe5\xf2"), "Привет"},
+		{"utf-8 header on latin-1 body", "text/xml; charset=UTF-8", rss(` encoding="ISO-8859-1"`, "Caf\xe9"), "Café"},
+		{"byte order mark", "application/rss+xml", append([]byte("\xef\xbb\xbf"), rss(` encoding="UTF-8"`, "Café")...), "Café"},
+	}
+	for _, tt := range tests {
+		feed, err := parseFeed(tt.contentType, tt.body)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if feed.Channel.Title != tt.want {
+			t.Errorf("%s: title %q, want %q", tt.name, feed.Channel.Title, tt.want)
+		}
+	}
+}
+
+func TestParseFeedUnknownCharset(t *testing.T) {
+	if _, err := parseFeed("application/rss+xml; charset=x-unknown", []byte(rssFixture)); err == nil {
+		t.Error("parseFeed with an unknown charset succeeded, want an error")
+	}
+}
+
+const rssExtensionsFixture = `<?xml version="1.0" encoding="utf-8"?>
+<rss version="2.0"
+  xmlns:atom="http://www.w3.org/2005/Atom"
+  xmlns:media="http://search.yahoo.com/mrss/"
+  xmlns:itunes="http://www.itunes.com/dtds/podcast-1.0.dtd"
+  xmlns:slash="http://purl.org/rss/1.0/modules/slash/">
+  <channel>
+    <title>Example Podcast</title>
+    <link>https://example.com/</link>
+    <description>Episodes</description>
+    <atom:link href="https://example.com/feed.xml" rel="self" type="application/rss+xml"/>
+    <itunes:author>Channel Host</itunes:author>
+    <media:title>Media channel title</media:title>
+    <item>
+      <title>Episode 1</title>
+      <link>https://example.com/1</link>
+      <description>Core summary</description>
+      <author>host@example.com (Host)</author>
+      <category>Tech</category>
+      <comments>https://example.com/1#comments</comments>
+      <slash:comments>4</slash:comments>
+      <atom:link href="https://example.com/1/alt" rel="alternate"/>
+      <media:title>Media title</media:title>
+      <media:description>Media description</media:description>
+      <media:category>media/category</media:category>
+      <itunes:author>iTunes Author</itunes:author>
+      <itunes:category text="Technology"/>
+    </item>
+    <item>
+      <media:title>Only media</media:title>
+      <itunes:author>Only iTunes</itunes:author>
+      <guid>urn:2</guid>
+    </item>
+  </channel>
+</rss>`
+
+func TestParseRSSIgnoresExtensionElements(t *testing.T) {
+	feed, err := parseFeed("application/rss+xml", []byte(rssExtensionsFixture))
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	if len(feed.Channel.Item) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Channel.Item))
+	}
+	first, second := feed.Channel.Item[0], feed.Channel.Item[1]
+	tests := []struct {
+		field, got, want string
+	}{
+		{"channel title", feed.Channel.Title, "Example Podcast"},
+		{"channel link", feed.Channel.Link, "https://example.com/"},
+		{"channel description", feed.Channel.Description, "Episodes"},
+		{"title", first.Title, "Episode 1"},
+		{"link", first.Link, "https://example.com/1"},
+		{"description", first.Description, "Core summary"},
+		{"author", first.Author, "Host"},
+		{"comments", first.CommentsURL, "https://example.com/1#comments"},
+		{"second title", second.Title, ""},
+		{"second link", second.Link, ""},
+		{"second author", second.Author, ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.field, tt.got, tt.want)
+		}
+	}
+	if !slices.Equal(first.Categories, []string{"Tech"}) {
+		t.Errorf("categories %q, want [Tech]", first.Categories)
+	}
+	if len(second.Categories) != 0 {
+		t.Errorf("second categories %q, want none", second.Categories)
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"context"
+	"crypto/md5"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Specter242/Gator/internal/database"
+)
+
+// The Fever API (https://feedafever.com/api) is spoken by many mobile
+// readers. Clients POST to /fever/?api with an api_key, the MD5 of
+// "username:password", and add query parameters naming what they want.
+// Like API tokens, only a SHA-256 hash of the key is stored.
+// Groups are the user's folders and items are posts of followed feeds.
+const (
+	feverAPIVersion   = 3
+	feverItemsPerPage = 50
+)
+
+// feverAPIKey is the key a Fever client sends for username and password.
+func feverAPIKey(username, password string) string {
+	sum := md5.Sum([]byte(username + ":" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+type feverServer struct {
+	s *state
+}
+
+func newFeverHandler(s *state) http.Handler {
+	return &feverServer{s: s}
+}
+
+type feverGroup struct {
+	ID    int32  `json:"id"`
+	Title string `json:"title"`
+}
+
+type feverFeedsGroup struct {
+	GroupID int32  `json:"group_id"`
+	FeedIDs string `json:"feed_ids"`
+}
+
+type feverFeed struct {
+	ID                int32  `json:"id"`
+	FaviconID         int    `json:"favicon_id"`
+	Title             string `json:"title"`
+	URL               string `json:"url"`
+	SiteURL           string `json:"site_url"`
+	IsSpark           int    `json:"is_spark"`
+	LastUpdatedOnTime int64  `json:"last_updated_on_time"`
+}
+
+type feverItem struct {
+	ID            int32  `json:"id"`
+	FeedID        int32  `json:"feed_id"`
+	Title         string `json:"title"`
+	Author        string `json:"author"`
+	HTML          string `json:"html"`
+	URL           string `json:"url"`
+	IsSaved       int    `json:"is_saved"`
+	IsRead        int    `json:"is_read"`
+	CreatedOnTime int64  `json:"created_on_time"`
+}
+
+func (f *feverServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	if _, ok := r.Form["api"]; !ok {
+		http.Error(w, "missing api parameter", http.StatusBadRequest)
+		return
+	}
+	resp := map[string]any{"api_version": feverAPIVersion, "auth": 0}
+	user, err := f.s.db.GetUserByFeverKey(r.Context(), hashAPIToken(strings.ToLower(r.FormValue("api_key"))))
+	if errors.Is(err, sql.ErrNoRows) {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	if err == nil {
+		resp["auth"] = 1
+		resp["last_refreshed_on_time"] = time.Now().Unix()
+		err = f.respond(r.Context(), r.Form, user, resp)
+	}
+	if err != nil {
+		// Not the raw query, which can carry the api_key.
+		log.Printf("fever mark=%q as=%q id=%q: %v", r.Form.Get("mark"), r.Form.Get("as"), r.Form.Get("id"), err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// respond fills in resp for every part of the API the request asks for.
+// Marks are applied first so the lists returned reflect them.
+func (f *feverServer) respond(ctx context.Context, form url.Values, user database.User, resp map[string]any) error {
+	if form.Get("mark") != "" {
+		if err := f.mark(ctx, form, user); err != nil {
+			return err
+		}
+	}
+	has := func(name string) bool {
+		_, ok := form[name]
+		return ok
+	}
+	if has("groups") {
+		folders, err := f.s.db.GetFoldersForUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("error getting folders: %v", err)
+		}
+		groups := make([]feverGroup, 0, len(folders))
+		for _, folder := range folders {
+			groups = append(groups, feverGroup{ID: folder.ID, Title: folder.Name})
+		}
+		resp["groups"] = groups
+	}
+	if has("feeds") {
+		feeds, err := f.s.db.GetFollowedFeeds(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("error getting feeds: %v", err)
+		}
+		items := make([]feverFeed, 0, len(feeds))
+		for _, feed := range feeds {
+			item := feverFeed{
+				ID:      feed.ID,
+				Title:   feed.Name,
+				URL:     feed.Url,
+				SiteURL: siteURL(feed.Url),
+			}
+			if feed.LastFetchedAt.Valid {
+				item.LastUpdatedOnTime = feed.LastFetchedAt.Time.Unix()
+			}
+			items = append(items, item)
+		}
+		resp["feeds"] = items
+	}
+	if has("groups") || has("feeds") {
+		feedsGroups, err := f.feedsGroups(ctx, user)
+		if err != nil {
+			return err
+		}
+		resp["feeds_groups"] = feedsGroups
+	}
+	if has("favicons") {
+		resp["favicons"] = []struct{}{}
+	}
+	if has("links") {
+		resp["links"] = []struct{}{}
+	}
+	if has("items") {
+		items, err := f.items(ctx, form, user)
+		if err != nil {
+			return err
+		}
+		total, err := f.s.db.CountPostsForUser(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("error counting posts: %v", err)
+		}
+		resp["items"] = items
+		resp["total_items"] = total
+	}
+	if has("unread_item_ids") {
+		ids, err := f.s.db.GetUnreadPostIDs(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("error getting unread posts: %v", err)
+		}
+		resp["unread_item_ids"] = joinIDs(ids)
+	}
+	if has("saved_item_ids") {
+		ids, err := f.s.db.GetStarredPostIDs(ctx, user.ID)
+		if err != nil {
+			return fmt.Errorf("error getting starred posts: %v", err)
+		}
+		resp["saved_item_ids"] = joinIDs(ids)
+	}
+	return nil
+}
+
+// feedsGroups lists the feeds in each folder, as Fever's feeds_groups.
+func (f *feverServer) feedsGroups(ctx context.Context, user database.User) ([]feverFeedsGroup, error) {
+	rows, err := f.s.db.GetFolderFeedsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting folder feeds: %v", err)
+	}
+	groups := []feverFeedsGroup{}
+	var ids []int32
+	for i, row := range rows {
+		ids = append(ids, row.FeedID)
+		if i == len(rows)-1 || rows[i+1].FolderID != row.FolderID {
+			groups = append(groups, feverFeedsGroup{GroupID: row.FolderID, FeedIDs: joinIDs(ids)})
+			ids = nil
+		}
+	}
+	return groups, nil
+}
+
+// items returns up to 50 items: those listed in with_ids, or those after
+// since_id, or those before max_id, or the oldest.
+func (f *feverServer) items(ctx context.Context, form url.Values, user database.User) ([]feverItem, error) {
+	params := database.GetPostsByIDForUserParams{
+		UserID: user.ID,
+		Limit:  feverItemsPerPage,
+	}
+	if v := form.Get("with_ids"); v != "" {
+		ids := parseIDs(v)
+		if len(ids) == 0 {
+			return []feverItem{}, nil
+		}
+		if len(ids) > feverItemsPerPage {
+			ids = ids[:feverItemsPerPage]
+		}
+		params.Ids = ids
+	} else if id, ok := parseFormID(form, "since_id"); ok {
+		params.SinceID = sql.NullInt32{Int32: id, Valid: true}
+	} else if id, ok := parseFormID(form, "max_id"); ok && id > 0 {
+		params.MaxID = sql.NullInt32{Int32: id, Valid: true}
+	}
+	posts, err := f.s.db.GetPostsByIDForUser(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("error getting posts: %v", err)
+	}
+	items := make([]feverItem, 0, len(posts))
+	for _, post := range posts {
+		html := post.Content.String
+		if html == "" {
+			html = post.Description.String
+		}
+		item := feverItem{
+			ID:            post.ID,
+			FeedID:        post.FeedID,
+			Title:         post.Title,
+			Author:        post.Author.String,
+			HTML:          html,
+			URL:           post.Url,
+			CreatedOnTime: post.PublishedAt.Unix(),
+		}
+		if post.ReadAt.Valid {
+			item.IsRead = 1
+		}
+		if post.StarredAt.Valid {
+			item.IsSaved = 1
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// mark applies mark=item|feed|group with as=read|unread|saved|unsaved.
+// Feeds and groups can only be marked read, up to the before timestamp;
+// group 0 is every feed. Unknown or foreign IDs are ignored, as in Fever.
+func (f *feverServer) mark(ctx context.Context, form url.Values, user database.User) error {
+	id, err := strconv.ParseInt(form.Get("id"), 10, 32)
+	if err != nil {
+		return nil
+	}
+	var before sql.NullTime
+	if ts, err := strconv.ParseInt(form.Get("before"), 10, 64); err == nil && ts > 0 {
+		before = sql.NullTime{Time: time.Unix(ts, 0).UTC(), Valid: true}
+	}
+	as := form.Get("as")
+	switch form.Get("mark") {
+	case "item":
+		return f.markItem(ctx, user, int32(id), as)
+	case "feed":
+		if as != "read" {
+			return nil
+		}
+		_, err = f.s.db.MarkPostsRead(ctx, database.MarkPostsReadParams{
+			UserID: user.ID,
+			FeedID: sql.NullInt32{Int32: int32(id), Valid: true},
+			Before: before,
+		})
+	case "group":
+		switch {
+		case as != "read" || id < 0:
+			// Negative IDs are Fever's Sparks, which Gator doesn't have.
+			return nil
+		case id == 0:
+			_, err = f.s.db.MarkPostsRead(ctx, database.MarkPostsReadParams{
+				UserID: user.ID,
+				Before: before,
+			})
+		default:
+			_, err = f.s.db.MarkFolderRead(ctx, database.MarkFolderReadParams{
+				UserID:   user.ID,
+				FolderID: int32(id),
+				Before:   before,
+			})
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("error marking posts read: %v", err)
+	}
+	return nil
+}
+
+func (f *feverServer) markItem(ctx context.Context, user database.User, postID int32, as string) error {
+	if as == "unsaved" {
+		_, err := f.s.db.UnstarPost(ctx, database.UnstarPostParams{UserID: user.ID, PostID: postID})
+		if err != nil {
+			return fmt.Errorf("error unstarring post: %v", err)
+		}
+		return nil
+	}
+	_, err := f.s.db.GetPostForUser(ctx, database.GetPostForUserParams{ID: postID, UserID: user.ID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("error getting post: %v", err)
+	}
+	switch as {
+	case "read":
+		err = f.s.db.MarkPostRead(ctx, database.MarkPostReadParams{UserID: user.ID, PostID: postID})
+	case "unread":
+		_, err = f.s.db.MarkPostUnread(ctx, database.MarkPostUnreadParams{UserID: user.ID, PostID: postID})
+	case "saved":
+		err = f.s.db.StarPost(ctx, database.StarPostParams{UserID: user.ID, PostID: postID})
+	}
+	if err != nil {
+		return fmt.Errorf("error marking post %s: %v", as, err)
+	}
+	return nil
+}
+
+func parseFormID(form url.Values, name string) (int32, bool) {
+	id, err := strconv.ParseInt(form.Get(name), 10, 32)
+	return int32(id), err == nil && id >= 0
+}
+
+// parseIDs reads a comma-separated list of IDs, skipping invalid ones.
+func parseIDs(list string) []int32 {
+	var ids []int32
+	for _, field := range strings.Split(list, ",") {
+		if id, err := strconv.ParseInt(strings.TrimSpace(field), 10, 32); err == nil {
+			ids = append(ids, int32(id))
+		}
+	}
+	return ids
+}
+
+func joinIDs(ids []int32) string {
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = strconv.Itoa(int(id))
+	}
+	return strings.Join(parts, ",")
+}
+
+// siteURL guesses a feed's site from its URL: the scheme and host.
+func siteURL(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil || u.Host == "" {
+		return feedURL
+	}
+	return u.Scheme + "://" + u.Host + "/"
+}
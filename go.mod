@@ -3,3 +3,13 @@ module github.com/Specter242/Gator
 go 1.23.4
 
 require github.com/lib/pq v1.10.9
+
+require (
+	golang.org/x/net v0.35.0
+	golang.org/x/term v0.29.0
+)
+
+require (
+	golang.org/x/sys v0.30.0 // indirect
+	golang.org/x/text v0.22.0 // indirect
+)
@@ -0,0 +1,568 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Specter242/Gator/internal/database"
+)
+
+// The Google Reader API is spoken by clients such as NetNewsWire and
+// FeedMe. They sign in with ClientLogin, using the Gator username and the
+// password set with the fever command, and send the token they get back as
+// "Authorization: GoogleLogin auth=<token>". Tokens are API tokens, so they
+// show up in token list and can be revoked there. Only the newest few are
+// kept, since clients sign in again whenever they like.
+//
+// Streams name sets of posts: the reading list (every followed feed),
+// starred posts, read posts, one feed ("feed/<id>") or one folder
+// ("user/-/label/<folder>").
+const (
+	greaderItemPrefix  = "tag:google.com,2005:reader/item/"
+	greaderReadingList = "user/-/state/com.google/reading-list"
+	greaderRead        = "user/-/state/com.google/read"
+	greaderStarred     = "user/-/state/com.google/starred"
+	greaderKeptUnread  = "user/-/state/com.google/kept-unread"
+	greaderLabelPrefix = "user/-/label/"
+	greaderFeedPrefix  = "feed/"
+	greaderTokenName   = "Google Reader client"
+	greaderMaxTokens   = 5
+
+	greaderDefaultItems = 20
+	greaderMaxItemIDs   = 10000
+	greaderMaxContents  = 1000
+)
+
+type greaderServer struct {
+	s *state
+}
+
+// greaderHandlerFunc handles a request signed in with a GoogleLogin token.
+type greaderHandlerFunc func(w http.ResponseWriter, r *http.Request, user database.User) error
+
+func newGReaderHandler(s *state) http.Handler {
+	g := &greaderServer{s: s}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /accounts/ClientLogin", g.clientLogin)
+	mux.Handle("/reader/api/0/token", g.auth(g.token))
+	mux.Handle("/reader/api/0/user-info", g.auth(g.userInfo))
+	mux.Handle("/reader/api/0/subscription/list", g.auth(g.subscriptionList))
+	mux.Handle("/reader/api/0/tag/list", g.auth(g.tagList))
+	mux.Handle("/reader/api/0/unread-count", g.auth(g.unreadCount))
+	mux.Handle("/reader/api/0/stream/items/ids", g.auth(g.streamItemIDs))
+	mux.Handle("/reader/api/0/stream/items/contents", g.auth(g.streamItemContents))
+	mux.Handle("/reader/api/0/stream/contents", g.auth(g.streamContents))
+	mux.Handle("/reader/api/0/stream/contents/{stream...}", g.auth(g.streamContents))
+	mux.Handle("POST /reader/api/0/edit-tag", g.auth(g.editTag))
+	return mux
+}
+
+// clientLogin checks Email and Passwd and answers with a new token,
+// signing out the user's oldest clients beyond greaderMaxTokens. The
+// credentials are only read from the POST body, never the URL.
+func (g *greaderServer) clientLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid form", http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	user, err := g.s.db.GetUserByFeverKey(ctx, hashAPIToken(feverAPIKey(r.PostFormValue("Email"), r.PostFormValue("Passwd"))))
+	if errors.Is(err, sql.ErrNoRows) {
+		http.Error(w, "Error=BadAuthentication", http.StatusUnauthorized)
+		return
+	}
+	var token, hash string
+	if err == nil {
+		token, hash, err = newAPIToken()
+	}
+	if err == nil {
+		_, err = g.s.db.CreateAPIToken(ctx, database.CreateAPITokenParams{
+			UserID:    user.ID,
+			Name:      greaderTokenName,
+			TokenHash: hash,
+		})
+	}
+	if err == nil {
+		_, err = g.s.db.DeleteOldAPITokens(ctx, database.DeleteOldAPITokensParams{
+			UserID: user.ID,
+			Name:   greaderTokenName,
+			Keep:   greaderMaxTokens,
+		})
+	}
+	if err != nil {
+		log.Printf("ClientLogin: %v", err)
+		http.Error(w, "internal server error", http.StatusInternalServerError)
+		return
+	}
+	if r.FormValue("output") == "json" {
+		writeJSON(w, http.StatusOK, map[string]string{"SID": token, "LSID": token, "Auth": token})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintf(w, "SID=%s\nLSID=%s\nAuth=%s\n", token, token, token)
+}
+
+func (g *greaderServer) auth(handler greaderHandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, params, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		token, ok := strings.CutPrefix(strings.TrimSpace(params), "auth=")
+		if !strings.EqualFold(scheme, "GoogleLogin") || !ok || token == "" {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		user, err := g.s.db.GetUserByAPIToken(r.Context(), hashAPIToken(token))
+		if errors.Is(err, sql.ErrNoRows) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err == nil {
+			err = r.ParseForm()
+		}
+		if err == nil {
+			err = handler(w, r, user)
+		}
+		if err != nil {
+			var apiErr *apiError
+			if errors.As(err, &apiErr) {
+				http.Error(w, apiErr.Message, apiErr.Status)
+				return
+			}
+			log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+		}
+	})
+}
+
+// token returns the token clients send back as T with edits. Requests are
+// authenticated by header rather than cookie, so it isn't checked.
+func (g *greaderServer) token(w http.ResponseWriter, r *http.Request, user database.User) error {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, hashAPIToken(r.Header.Get("Authorization")))
+	return nil
+}
+
+func (g *greaderServer) userInfo(w http.ResponseWriter, r *http.Request, user database.User) error {
+	id := strconv.Itoa(int(user.ID))
+	writeJSON(w, http.StatusOK, map[string]string{
+		"userId":        id,
+		"userName":      user.Name,
+		"userProfileId": id,
+		"userEmail":     "",
+	})
+	return nil
+}
+
+type greaderCategory struct {
+	ID    string `json:"id"`
+	Label string `json:"label"`
+}
+
+type greaderSubscription struct {
+	ID         string            `json:"id"`
+	Title      string            `json:"title"`
+	Categories []greaderCategory `json:"categories"`
+	URL        string            `json:"url"`
+	HTMLURL    string            `json:"htmlUrl"`
+	IconURL    string            `json:"iconUrl"`
+}
+
+// feedFolders maps each of the user's followed feeds to its folders.
+func (g *greaderServer) feedFolders(ctx context.Context, user database.User) (map[int32][]string, error) {
+	folders, err := g.s.db.GetFoldersForUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting folders: %v", err)
+	}
+	names := make(map[int32]string, len(folders))
+	for _, folder := range folders {
+		names[folder.ID] = folder.Name
+	}
+	rows, err := g.s.db.GetFolderFeedsForUser(ctx, user.ID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting folder feeds: %v", err)
+	}
+	feedFolders := make(map[int32][]string)
+	for _, row := range rows {
+		feedFolders[row.FeedID] = append(feedFolders[row.FeedID], names[row.FolderID])
+	}
+	return feedFolders, nil
+}
+
+func (g *greaderServer) subscriptionList(w http.ResponseWriter, r *http.Request, user database.User) error {
+	feeds, err := g.s.db.GetFollowedFeeds(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting feeds: %v", err)
+	}
+	feedFolders, err := g.feedFolders(r.Context(), user)
+	if err != nil {
+		return err
+	}
+	subscriptions := make([]greaderSubscription, 0, len(feeds))
+	for _, feed := range feeds {
+		categories := []greaderCategory{}
+		for _, folder := range feedFolders[feed.ID] {
+			categories = append(categories, greaderCategory{ID: greaderLabelPrefix + folder, Label: folder})
+		}
+		subscriptions = append(subscriptions, greaderSubscription{
+			ID:         greaderFeedPrefix + strconv.Itoa(int(feed.ID)),
+			Title:      feed.Name,
+			Categories: categories,
+			URL:        feed.Url,
+			HTMLURL:    siteURL(feed.Url),
+		})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"subscriptions": subscriptions})
+	return nil
+}
+
+func (g *greaderServer) tagList(w http.ResponseWriter, r *http.Request, user database.User) error {
+	folders, err := g.s.db.GetFoldersForUser(r.Context(), user.ID)
+	if err != nil {
+		return fmt.Errorf("error getting folders: %v", err)
+	}
+	tags := []map[string]string{{"id": greaderStarred}}
+	for _, folder := range folders {
+		tags = append(tags, map[string]string{"id": greaderLabelPrefix + folder.Name, "type": "folder"})
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"tags": tags})
+	return nil
+}
+
+func (g *greaderServer) unreadCount(w http.ResponseWriter, r *http.Request, user database.User) error {
+	follows, err := g.s.db.GetFeedFollowsForUser(r.Context(), database.GetFeedFollowsForUserParams{
+		UserID: user.ID,
+		Limit:  greaderMaxContents,
+	})
+	if err != nil {
+		return fmt.Errorf("error getting follows: %v", err)
+	}
+	counts := make([]map[string]any, 0, len(follows)+1)
+	var total int64
+	for _, follow := range follows {
+		total += follow.UnreadCount
+		counts = append(counts, map[string]any{
+			"id":    greaderFeedPrefix + strconv.Itoa(int(follow.FeedID)),
+			"count": follow.UnreadCount,
+		})
+	}
+	counts = append(counts, map[string]any{"id": greaderReadingList, "count": total})
+	writeJSON(w, http.StatusOK, map[string]any{"max": total, "unreadcounts": counts})
+	return nil
+}
+
+// streamQuery reads a stream request's parameters: the stream, n for how
+// many items, xt to exclude a state (only read is supported), ot and nt
+// for the oldest and newest publication times in seconds, r=o for oldest
+// first and c for the continuation returned by the previous page.
+func streamQuery(user database.User, stream string, form url.Values, maxItems int) (database.GetStreamPostIDsParams, error) {
+	params := database.GetStreamPostIDsParams{
+		UserID: user.ID,
+		Limit:  greaderDefaultItems,
+	}
+	stream = normalizeStreamID(stream)
+	switch {
+	case stream == "" || stream == greaderReadingList:
+	case stream == greaderStarred:
+		params.StarredOnly = true
+	case stream == greaderRead:
+		params.Read = sql.NullBool{Bool: true, Valid: true}
+	case strings.HasPrefix(stream, greaderLabelPrefix):
+		folder := strings.TrimPrefix(stream, greaderLabelPrefix)
+		params.Folder = sql.NullString{String: folder, Valid: true}
+	case strings.HasPrefix(stream, greaderFeedPrefix):
+		id, err := strconv.ParseInt(strings.TrimPrefix(stream, greaderFeedPrefix), 10, 32)
+		if err != nil {
+			return params, errBadRequest("unknown feed stream: %s", stream)
+		}
+		params.FeedID = sql.NullInt32{Int32: int32(id), Valid: true}
+	default:
+		return params, errBadRequest("unknown stream: %s", stream)
+	}
+	for _, exclude := range form["xt"] {
+		if normalizeStreamID(exclude) == greaderRead {
+			params.Read = sql.NullBool{Bool: false, Valid: true}
+		}
+	}
+	if v := form.Get("n"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return params, errBadRequest("invalid n: %s", v)
+		}
+		params.Limit = int32(min(n, maxItems))
+	}
+	if v := form.Get("c"); v != "" {
+		offset, err := strconv.ParseInt(v, 10, 32)
+		if err != nil || offset < 0 {
+			return params, errBadRequest("invalid continuation: %s", v)
+		}
+		params.Offset = int32(offset)
+	}
+	if ts, err := strconv.ParseInt(form.Get("ot"), 10, 64); err == nil && ts > 0 {
+		params.PublishedAfter = sql.NullTime{Time: time.Unix(ts, 0).UTC(), Valid: true}
+	}
+	if ts, err := strconv.ParseInt(form.Get("nt"), 10, 64); err == nil && ts > 0 {
+		params.PublishedBefore = sql.NullTime{Time: time.Unix(ts, 0).UTC(), Valid: true}
+	}
+	params.OldestFirst = form.Get("r") == "o"
+	return params, nil
+}
+
+// normalizeStreamID replaces the user ID in "user/<id>/..." with "-".
+func normalizeStreamID(stream string) string {
+	rest, ok := strings.CutPrefix(stream, "user/")
+	if !ok {
+		return stream
+	}
+	if _, tail, ok := strings.Cut(rest, "/"); ok {
+		return "user/-/" + tail
+	}
+	return stream
+}
+
+// continuation is the c value for the page after ids, if there may be one.
+func continuation(params database.GetStreamPostIDsParams, ids []int32) string {
+	if len(ids) < int(params.Limit) {
+		return ""
+	}
+	return strconv.Itoa(int(params.Offset) + len(ids))
+}
+
+func (g *greaderServer) streamItemIDs(w http.ResponseWriter, r *http.Request, user database.User) error {
+	params, err := streamQuery(user, r.Form.Get("s"), r.Form, greaderMaxItemIDs)
+	if err != nil {
+		return err
+	}
+	ids, err := g.s.db.GetStreamPostIDs(r.Context(), params)
+	if err != nil {
+		return fmt.Errorf("error getting stream: %v", err)
+	}
+	refs := make([]map[string]string, 0, len(ids))
+	for _, id := range ids {
+		refs = append(refs, map[string]string{"id": strconv.Itoa(int(id))})
+	}
+	resp := map[string]any{"itemRefs": refs}
+	if c := continuation(params, ids); c != "" {
+		resp["continuation"] = c
+	}
+	writeJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+func (g *greaderServer) streamContents(w http.ResponseWriter, r *http.Request, user database.User) error {
+	stream := r.PathValue("stream")
+	params, err := streamQuery(user, stream, r.Form, greaderMaxContents)
+	if err != nil {
+		return err
+	}
+	ids, err := g.s.db.GetStreamPostIDs(r.Context(), params)
+	if err != nil {
+		return fmt.Errorf("error getting stream: %v", err)
+	}
+	items, err := g.items(r.Context(), user, ids)
+	if err != nil {
+		return err
+	}
+	if stream == "" {
+		stream = greaderReadingList
+	}
+	resp := map[string]any{
+		"id":      stream,
+		"updated": time.Now().Unix(),
+		"items":   items,
+	}
+	if c := continuation(params, ids); c != "" {
+		resp["continuation"] = c
+	}
+	writeJSON(w, http.StatusOK, resp)
+	return nil
+}
+
+// streamItemContents returns the items whose IDs are given as i.
+func (g *greaderServer) streamItemContents(w http.ResponseWriter, r *http.Request, user database.User) error {
+	ids := greaderItemIDs(r.Form["i"])
+	if len(ids) > greaderMaxContents {
+		ids = ids[:greaderMaxContents]
+	}
+	items, err := g.items(r.Context(), user, ids)
+	if err != nil {
+		return err
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"id":      greaderReadingList,
+		"updated": time.Now().Unix(),
+		"items":   items,
+	})
+	return nil
+}
+
+// greaderItemIDs reads item IDs in either the long form,
+// "tag:google.com,2005:reader/item/<hex>", or the short decimal form,
+// skipping invalid ones.
+func greaderItemIDs(values []string) []int32 {
+	var ids []int32
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		var id int64
+		var err error
+		if hexID, ok := strings.CutPrefix(v, greaderItemPrefix); ok {
+			id, err = strconv.ParseInt(hexID, 16, 64)
+		} else {
+			id, err = strconv.ParseInt(v, 10, 64)
+		}
+		if err == nil && id > 0 && id <= math.MaxInt32 {
+			ids = append(ids, int32(id))
+		}
+	}
+	return ids
+}
+
+type greaderLink struct {
+	Href string `json:"href"`
+	Type string `json:"type,omitempty"`
+}
+
+type greaderItem struct {
+	ID            string            `json:"id"`
+	CrawlTimeMsec string            `json:"crawlTimeMsec"`
+	TimestampUsec string            `json:"timestampUsec"`
+	Published     int64             `json:"published"`
+	Updated       int64             `json:"updated"`
+	Title         string            `json:"title"`
+	Author        string            `json:"author,omitempty"`
+	Canonical     []greaderLink     `json:"canonical"`
+	Alternate     []greaderLink     `json:"alternate"`
+	Categories    []string          `json:"categories"`
+	Origin        map[string]string `json:"origin"`
+	Summary       map[string]string `json:"summary"`
+}
+
+// items loads the posts with the given IDs from the user's followed feeds
+// and renders them as Google Reader items, in the order of ids.
+func (g *greaderServer) items(ctx context.Context, user database.User, ids []int32) ([]greaderItem, error) {
+	items := make([]greaderItem, 0, len(ids))
+	if len(ids) == 0 {
+		return items, nil
+	}
+	posts, err := g.s.db.GetPostsByIDForUser(ctx, database.GetPostsByIDForUserParams{
+		UserID: user.ID,
+		Ids:    ids,
+		Limit:  int32(len(ids)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error getting posts: %v", err)
+	}
+	feedFolders, err := g.feedFolders(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int32]database.GetPostsByIDForUserRow, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+	}
+	for _, id := range ids {
+		post, ok := byID[id]
+		if !ok {
+			continue
+		}
+		categories := []string{greaderReadingList}
+		if post.ReadAt.Valid {
+			categories = append(categories, greaderRead)
+		}
+		if post.StarredAt.Valid {
+			categories = append(categories, greaderStarred)
+		}
+		for _, folder := range feedFolders[post.FeedID] {
+			categories = append(categories, greaderLabelPrefix+folder)
+		}
+		html := post.Content.String
+		if html == "" {
+			html = post.Description.String
+		}
+		items = append(items, greaderItem{
+			ID:            fmt.Sprintf("%s%016x", greaderItemPrefix, post.ID),
+			CrawlTimeMsec: strconv.FormatInt(post.CreatedAt.UnixMilli(), 10),
+			TimestampUsec: strconv.FormatInt(post.PublishedAt.UnixMicro(), 10),
+			Published:     post.PublishedAt.Unix(),
+			Updated:       post.UpdatedAt.Unix(),
+			Title:         post.Title,
+			Author:        post.Author.String,
+			Canonical:     []greaderLink{{Href: post.Url}},
+			Alternate:     []greaderLink{{Href: post.Url, Type: "text/html"}},
+			Categories:    categories,
+			Origin: map[string]string{
+				"streamId": greaderFeedPrefix + strconv.Itoa(int(post.FeedID)),
+				"title":    post.FeedName,
+			},
+			Summary: map[string]string{"direction": "ltr", "content": html},
+		})
+	}
+	return items, nil
+}
+
+// editTag adds the tags in a to and removes those in r from the items in
+// i. Only the read, kept-unread and starred states are supported; other
+// tags and posts outside the user's followed feeds are ignored.
+func (g *greaderServer) editTag(w http.ResponseWriter, r *http.Request, user database.User) error {
+	ctx := r.Context()
+	ids := greaderItemIDs(r.Form["i"])
+	if len(ids) == 0 {
+		return errBadRequest("no items given")
+	}
+	posts, err := g.s.db.GetPostsByIDForUser(ctx, database.GetPostsByIDForUserParams{
+		UserID: user.ID,
+		Ids:    ids,
+		Limit:  int32(len(ids)),
+	})
+	if err != nil {
+		return fmt.Errorf("error getting posts: %v", err)
+	}
+	var read, starred sql.NullBool
+	for _, tag := range r.Form["a"] {
+		switch normalizeStreamID(tag) {
+		case greaderRead:
+			read = sql.NullBool{Bool: true, Valid: true}
+		case greaderKeptUnread:
+			read = sql.NullBool{Bool: false, Valid: true}
+		case greaderStarred:
+			starred = sql.NullBool{Bool: true, Valid: true}
+		}
+	}
+	for _, tag := range r.Form["r"] {
+		switch normalizeStreamID(tag) {
+		case greaderRead:
+			read = sql.NullBool{Bool: false, Valid: true}
+		case greaderStarred:
+			starred = sql.NullBool{Bool: false, Valid: true}
+		}
+	}
+	for _, post := range posts {
+		key := database.MarkPostReadParams{UserID: user.ID, PostID: post.ID}
+		if read.Valid && read.Bool {
+			err = g.s.db.MarkPostRead(ctx, key)
+		} else if read.Valid {
+			_, err = g.s.db.MarkPostUnread(ctx, database.MarkPostUnreadParams(key))
+		}
+		if err != nil {
+			return fmt.Errorf("error marking post %d: %v", post.ID, err)
+		}
+		if starred.Valid && starred.Bool {
+			err = g.s.db.StarPost(ctx, database.StarPostParams(key))
+		} else if starred.Valid {
+			_, err = g.s.db.UnstarPost(ctx, database.UnstarPostParams(key))
+		}
+		if err != nil {
+			return fmt.Errorf("error starring post %d: %v", post.ID, err)
+		}
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprint(w, "OK")
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Specter242/Gator/internal/database"
+)
+
+const (
+	// maxConsecutiveFailures is how many failed fetches in a row disable a
+	// feed until it is re-enabled with enablefeed.
+	maxConsecutiveFailures = 10
+	minBackoff             = 5 * time.Minute
+	maxBackoff             = 24 * time.Hour
+)
+
+// backoff returns how long to wait after the given number of consecutive
+// failures: doubling from minBackoff up to maxBackoff, but never sooner than
+// the feed's regular interval.
+func backoff(failures int32, interval time.Duration) time.Duration {
+	wait := maxBackoff
+	if failures < 20 {
+		wait = min(minBackoff<<failures, maxBackoff)
+	}
+	return max(wait, interval)
+}
+
+// recordFeedHealth stores the outcome of a scrape. Failures push the next
+// fetch back exponentially and eventually disable the feed.
+func recordFeedHealth(ctx context.Context, s *state, feed database.Feed, result scrapeResult, scrapeErr error) error {
+	if scrapeErr == nil {
+		err := s.db.RecordFeedSuccess(ctx, database.RecordFeedSuccessParams{
+			ID:                 feed.ID,
+			LastStatus:         sql.NullInt32{Int32: int32(result.StatusCode), Valid: result.StatusCode != 0},
+			LastScrapeInserted: int32(result.Inserted),
+			LastScrapeUpdated:  int32(result.Updated),
+			LastScrapeSkipped:  int32(result.Skipped),
+			LastScrapeErrors:   joinScrapeErrors(result.Errors),
+		})
+		if err != nil {
+			return fmt.Errorf("error recording feed success: %v", err)
+		}
+		return nil
+	}
+	status := result.StatusCode
+	var statusErr *statusError
+	if errors.As(scrapeErr, &statusErr) {
+		status = statusErr.StatusCode
+	}
+	interval, err := fetchInterval(ctx, s, feed)
+	if err != nil {
+		interval = defaultFetchInterval
+	}
+	wait := backoff(feed.ConsecutiveFailures, interval)
+	maxFailures := int32(maxConsecutiveFailures)
+	if status == http.StatusGone {
+		// The publisher says the feed is gone for good; don't retry it.
+		maxFailures = 1
+	}
+	row, err := s.db.RecordFeedFailure(ctx, database.RecordFeedFailureParams{
+		ID:             feed.ID,
+		LastError:      sql.NullString{String: scrapeErr.Error(), Valid: true},
+		LastStatus:     sql.NullInt32{Int32: int32(status), Valid: status != 0},
+		BackoffSeconds: int32(wait / time.Second),
+		MaxFailures:    maxFailures,
+	})
+	if err != nil {
+		return fmt.Errorf("error recording feed failure: %v", err)
+	}
+	switch {
+	case !row.DisabledAt.Valid || feed.DisabledAt.Valid:
+	case status == http.StatusGone:
+		log.Printf("Feed %s is gone (410) and has been disabled", feed.Name)
+	default:
+		log.Printf("Feed %s disabled after %d consecutive failures", feed.Name, row.ConsecutiveFailures)
+	}
+	return nil
+}
+
+// maxStoredScrapeErrors caps how many item errors are kept per feed.
+const maxStoredScrapeErrors = 20
+
+func joinScrapeErrors(errs []error) sql.NullString {
+	if len(errs) == 0 {
+		return sql.NullString{}
+	}
+	var lines []string
+	for i, err := range errs {
+		if i == maxStoredScrapeErrors {
+			lines = append(lines, fmt.Sprintf("... and %d more", len(errs)-i))
+			break
+		}
+		lines = append(lines, err.Error())
+	}
+	return sql.NullString{String: strings.Join(lines, "\n"), Valid: true}
+}
+
+// feedHealth summarizes a feed's state for the feeds --health view.
+func feedHealth(feed database.Feed) string {
+	switch {
+	case feed.DisabledAt.Valid && feed.LastStatus.Int32 == http.StatusGone:
+		return "dead (410 Gone)"
+	case feed.DisabledAt.Valid:
+		return "disabled"
+	case feed.ConsecutiveFailures > 0:
+		return fmt.Sprintf("failing (%d in a row)", feed.ConsecutiveFailures)
+	case !feed.LastFetchedAt.Valid:
+		return "never fetched"
+	}
+	return "ok"
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// blockTags start a new line when rendering HTML as text.
+var blockTags = map[atom.Atom]bool{
+	atom.P: true, atom.Div: true, atom.Br: true, atom.Li: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Blockquote: true, atom.Pre: true, atom.Tr: true, atom.Hr: true,
+	atom.Ul: true, atom.Ol: true, atom.Table: true, atom.Figure: true,
+}
+
+var (
+	spaceRun = regexp.MustCompile(`[ \t\r\f\v]+`)
+	blankRun = regexp.MustCompile(`\n\s*\n(\s*\n)+`)
+)
+
+// htmlToText renders post content as plain text for the terminal: markup
+// is dropped, block elements become line breaks, list items get a bullet
+// and scripts and styles are removed.
+func htmlToText(s string) string {
+	var b strings.Builder
+	z := html.NewTokenizer(strings.NewReader(s))
+	skip := 0
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			text := spaceRun.ReplaceAllString(b.String(), " ")
+			lines := strings.Split(text, "\n")
+			for i, line := range lines {
+				lines[i] = strings.TrimSpace(line)
+			}
+			text = blankRun.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+			return strings.TrimSpace(text)
+		case html.TextToken:
+			if skip == 0 {
+				b.WriteString(strings.ReplaceAll(string(z.Text()), "\n", " "))
+			}
+		case html.StartTagToken, html.SelfClosingTagToken, html.EndTagToken:
+			name, _ := z.TagName()
+			tag := atom.Lookup(name)
+			if tag == atom.Script || tag == atom.Style {
+				if tt == html.StartTagToken {
+					skip++
+				} else if tt == html.EndTagToken && skip > 0 {
+					skip--
+				}
+				continue
+			}
+			if !blockTags[tag] || (tag == atom.Li && tt == html.EndTagToken) {
+				continue
+			}
+			b.WriteString("\n")
+			if tag == atom.P && tt == html.EndTagToken {
+				b.WriteString("\n")
+			}
+			if tag == atom.Li {
+				b.WriteString("• ")
+			}
+		}
+	}
+}
@@ -15,6 +15,14 @@ type Config struct {
 
 	// CurrentUserName is the username for the current user
 	CurrentUserName string `json:"current_user_name"`
+
+	// RetentionMaxAge is how long posts are kept, as in "90d" or "720h";
+	// empty keeps them regardless of age
+	RetentionMaxAge string `json:"retention_max_age,omitempty"`
+
+	// RetentionMaxPosts is how many of each feed's newest posts are kept;
+	// zero keeps them all
+	RetentionMaxPosts int `json:"retention_max_posts,omitempty"`
 }
 
 // GetHomeDir returns the user's home directory
@@ -9,14 +9,55 @@ import (
 	"time"
 )
 
+type ApiToken struct {
+	ID         int32
+	CreatedAt  time.Time
+	UserID     int32
+	Name       string
+	TokenHash  string
+	LastUsedAt sql.NullTime
+}
+
+type Enclosure struct {
+	ID              int32
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	PostID          int32
+	Url             string
+	MimeType        sql.NullString
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+}
+
 type Feed struct {
-	ID            int32
-	CreatedAt     time.Time
-	UpdatedAt     time.Time
-	Name          string
-	Url           string
-	UserID        int32
-	LastFetchedAt sql.NullTime
+	ID                     int32
+	CreatedAt              time.Time
+	UpdatedAt              time.Time
+	Name                   string
+	Url                    string
+	UserID                 int32
+	LastFetchedAt          sql.NullTime
+	Etag                   sql.NullString
+	LastModified           sql.NullString
+	FetchIntervalSeconds   sql.NullInt32
+	AdaptiveSchedule       bool
+	NextFetchAt            sql.NullTime
+	ConsecutiveFailures    int32
+	LastError              sql.NullString
+	LastStatus             sql.NullInt32
+	LastSuccessAt          sql.NullTime
+	DisabledAt             sql.NullTime
+	MovedToUrl             sql.NullString
+	MovedCount             int32
+	LastScrapeInserted     int32
+	LastScrapeUpdated      int32
+	LastScrapeSkipped      int32
+	LastScrapeErrors       sql.NullString
+	RetentionMaxAgeSeconds sql.NullInt32
+	RetentionMaxPosts      sql.NullInt32
+	MinIntervalSeconds     sql.NullInt32
+	SkipHours              []int32
+	SkipDays               []int32
 }
 
 type FeedFollow struct {
@@ -27,15 +68,50 @@ type FeedFollow struct {
 	FeedID    int32
 }
 
+type FeedFollowFolder struct {
+	FeedFollowID int32
+	FolderID     int32
+}
+
+type FeverKey struct {
+	UserID    int32
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	KeyHash   string
+}
+
+type Folder struct {
+	ID        int32
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	UserID    int32
+	Name      string
+}
+
 type Post struct {
-	ID          int32
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      int32
+	ID           int32
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+	Title        string
+	Url          string
+	Description  sql.NullString
+	PublishedAt  time.Time
+	FeedID       int32
+	Guid         string
+	Content      sql.NullString
+	Author       sql.NullString
+	CommentsUrl  sql.NullString
+	Categories   []string
+	SearchVector interface{}
+}
+
+type PostState struct {
+	UserID    int32
+	PostID    int32
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	ReadAt    sql.NullTime
+	StarredAt sql.NullTime
 }
 
 type User struct {
@@ -9,18 +9,216 @@ import (
 	"context"
 	"database/sql"
 	"time"
+
+	"github.com/lib/pq"
 )
 
-const createFeed = `-- name: CreateFeed :one
-INSERT INTO feeds (name, url, user_id)
+const addFeedFollowToFolder = `-- name: AddFeedFollowToFolder :exec
+INSERT INTO feed_follow_folders (feed_follow_id, folder_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING
+`
+
+type AddFeedFollowToFolderParams struct {
+	FeedFollowID int32
+	FolderID     int32
+}
+
+func (q *Queries) AddFeedFollowToFolder(ctx context.Context, arg AddFeedFollowToFolderParams) error {
+	_, err := q.db.ExecContext(ctx, addFeedFollowToFolder, arg.FeedFollowID, arg.FolderID)
+	return err
+}
+
+const adoptLegacyPost = `-- name: AdoptLegacyPost :exec
+UPDATE posts
+SET guid = $1
+WHERE posts.feed_id = $2
+  AND posts.guid = $3
+  AND posts.guid <> $1
+  AND NOT EXISTS (
+      SELECT 1 FROM posts AS existing
+      WHERE existing.feed_id = $2
+        AND existing.guid = $1
+  )
+`
+
+type AdoptLegacyPostParams struct {
+	Guid   string
+	FeedID int32
+	Url    string
+}
+
+// Posts stored before guids were tracked are keyed by their link. This
+// moves such a post over to the item's guid so the next upsert updates it
+// instead of storing the item a second time.
+func (q *Queries) AdoptLegacyPost(ctx context.Context, arg AdoptLegacyPostParams) error {
+	_, err := q.db.ExecContext(ctx, adoptLegacyPost, arg.Guid, arg.FeedID, arg.Url)
+	return err
+}
+
+const blockFeedMove = `-- name: BlockFeedMove :exec
+UPDATE feeds
+SET moved_count = 0
+WHERE id = $1
+`
+
+// Keeps the URL a feed redirects to but stops counting towards the move,
+// for when another feed already has that URL.
+func (q *Queries) BlockFeedMove(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, blockFeedMove, id)
+	return err
+}
+
+const claimFeedsToFetch = `-- name: ClaimFeedsToFetch :many
+UPDATE feeds
+SET last_fetched_at = NOW(),
+    next_fetch_at = NOW() + INTERVAL '1 hour',
+    updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE disabled_at IS NULL
+      AND (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    ORDER BY next_fetch_at ASC NULLS FIRST
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, adaptive_schedule, next_fetch_at, consecutive_failures, last_error, last_status, last_success_at, disabled_at, moved_to_url, moved_count, last_scrape_inserted, last_scrape_updated, last_scrape_skipped, last_scrape_errors, retention_max_age_seconds, retention_max_posts, min_interval_seconds, skip_hours, skip_days
+`
+
+// Claimed feeds are leased for an hour so a crashed scrape is retried later
+// rather than on every cycle; ScheduleNextFetch replaces the lease.
+func (q *Queries) ClaimFeedsToFetch(ctx context.Context, limit int32) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, claimFeedsToFetch, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.FetchIntervalSeconds,
+			&i.AdaptiveSchedule,
+			&i.NextFetchAt,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastStatus,
+			&i.LastSuccessAt,
+			&i.DisabledAt,
+			&i.MovedToUrl,
+			&i.MovedCount,
+			&i.LastScrapeInserted,
+			&i.LastScrapeUpdated,
+			&i.LastScrapeSkipped,
+			&i.LastScrapeErrors,
+			&i.RetentionMaxAgeSeconds,
+			&i.RetentionMaxPosts,
+			&i.MinIntervalSeconds,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const clearFeedMove = `-- name: ClearFeedMove :exec
+UPDATE feeds
+SET moved_to_url = NULL,
+    moved_count = 0
+WHERE id = $1
+`
+
+func (q *Queries) ClearFeedMove(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, clearFeedMove, id)
+	return err
+}
+
+const countPostsForUser = `-- name: CountPostsForUser :one
+SELECT COUNT(*) FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = $1
+`
+
+func (q *Queries) CountPostsForUser(ctx context.Context, userID int32) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countPostsForUser, userID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const countRecentPosts = `-- name: CountRecentPosts :one
+SELECT COUNT(*) FROM posts
+WHERE feed_id = $1 AND published_at > $2
+`
+
+type CountRecentPostsParams struct {
+	FeedID      int32
+	PublishedAt time.Time
+}
+
+func (q *Queries) CountRecentPosts(ctx context.Context, arg CountRecentPostsParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countRecentPosts, arg.FeedID, arg.PublishedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
+const createAPIToken = `-- name: CreateAPIToken :one
+INSERT INTO api_tokens (user_id, name, token_hash)
 VALUES ($1, $2, $3)
+RETURNING id, created_at, user_id, name, token_hash, last_used_at
+`
+
+type CreateAPITokenParams struct {
+	UserID    int32
+	Name      string
+	TokenHash string
+}
+
+func (q *Queries) CreateAPIToken(ctx context.Context, arg CreateAPITokenParams) (ApiToken, error) {
+	row := q.db.QueryRowContext(ctx, createAPIToken, arg.UserID, arg.Name, arg.TokenHash)
+	var i ApiToken
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UserID,
+		&i.Name,
+		&i.TokenHash,
+		&i.LastUsedAt,
+	)
+	return i, err
+}
+
+const createFeed = `-- name: CreateFeed :one
+INSERT INTO feeds (name, url, user_id, fetch_interval_seconds, adaptive_schedule)
+VALUES ($1, $2, $3, $4, $5)
 RETURNING id, created_at, updated_at, name, url, user_id
 `
 
 type CreateFeedParams struct {
-	Name   string
-	Url    string
-	UserID int32
+	Name                 string
+	Url                  string
+	UserID               int32
+	FetchIntervalSeconds sql.NullInt32
+	AdaptiveSchedule     bool
 }
 
 type CreateFeedRow struct {
@@ -33,7 +231,13 @@ type CreateFeedRow struct {
 }
 
 func (q *Queries) CreateFeed(ctx context.Context, arg CreateFeedParams) (CreateFeedRow, error) {
-	row := q.db.QueryRowContext(ctx, createFeed, arg.Name, arg.Url, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createFeed,
+		arg.Name,
+		arg.Url,
+		arg.UserID,
+		arg.FetchIntervalSeconds,
+		arg.AdaptiveSchedule,
+	)
 	var i CreateFeedRow
 	err := row.Scan(
 		&i.ID,
@@ -107,42 +311,6 @@ func (q *Queries) CreateFeedFollow(ctx context.Context, arg CreateFeedFollowPara
 	return items, nil
 }
 
-const createPost = `-- name: CreatePost :one
-INSERT INTO posts (title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id
-`
-
-type CreatePostParams struct {
-	Title       string
-	Url         string
-	Description sql.NullString
-	PublishedAt time.Time
-	FeedID      int32
-}
-
-func (q *Queries) CreatePost(ctx context.Context, arg CreatePostParams) (Post, error) {
-	row := q.db.QueryRowContext(ctx, createPost,
-		arg.Title,
-		arg.Url,
-		arg.Description,
-		arg.PublishedAt,
-		arg.FeedID,
-	)
-	var i Post
-	err := row.Scan(
-		&i.ID,
-		&i.CreatedAt,
-		&i.UpdatedAt,
-		&i.Title,
-		&i.Url,
-		&i.Description,
-		&i.PublishedAt,
-		&i.FeedID,
-	)
-	return i, err
-}
-
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (name)
 VALUES ($1)
@@ -161,52 +329,139 @@ func (q *Queries) CreateUser(ctx context.Context, name string) (User, error) {
 	return i, err
 }
 
-const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
-SELECT
-    feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id,
-    feeds.name AS feed_name,
-    users.name AS user_name
-FROM feed_follows
-JOIN feeds ON feed_follows.feed_id = feeds.id
-JOIN users ON feed_follows.user_id = users.id
-WHERE feed_follows.user_id = $1
-ORDER BY feed_follows.created_at DESC
-LIMIT $2 OFFSET $3
+const deleteAPIToken = `-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens
+WHERE id = $1 AND user_id = $2
 `
 
-type GetFeedFollowsForUserParams struct {
+type DeleteAPITokenParams struct {
+	ID     int32
 	UserID int32
-	Limit  int32
-	Offset int32
 }
 
-type GetFeedFollowsForUserRow struct {
-	ID        int32
-	CreatedAt time.Time
-	UpdatedAt time.Time
-	UserID    int32
-	FeedID    int32
-	FeedName  string
-	UserName  string
+func (q *Queries) DeleteAPIToken(ctx context.Context, arg DeleteAPITokenParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteAPIToken, arg.ID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-func (q *Queries) GetFeedFollowsForUser(ctx context.Context, arg GetFeedFollowsForUserParams) ([]GetFeedFollowsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, arg.UserID, arg.Limit, arg.Offset)
+const deleteEmptyFolders = `-- name: DeleteEmptyFolders :exec
+DELETE FROM folders
+WHERE user_id = $1
+  AND NOT EXISTS (
+      SELECT 1 FROM feed_follow_folders
+      WHERE feed_follow_folders.folder_id = folders.id
+  )
+`
+
+func (q *Queries) DeleteEmptyFolders(ctx context.Context, userID int32) error {
+	_, err := q.db.ExecContext(ctx, deleteEmptyFolders, userID)
+	return err
+}
+
+const deleteFeverKey = `-- name: DeleteFeverKey :execrows
+DELETE FROM fever_keys
+WHERE user_id = $1
+`
+
+func (q *Queries) DeleteFeverKey(ctx context.Context, userID int32) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteFeverKey, userID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const deleteOldAPITokens = `-- name: DeleteOldAPITokens :execrows
+DELETE FROM api_tokens
+WHERE api_tokens.user_id = $1
+  AND api_tokens.name = $2
+  AND api_tokens.id NOT IN (
+      SELECT newest.id FROM api_tokens AS newest
+      WHERE newest.user_id = $1 AND newest.name = $2
+      ORDER BY newest.created_at DESC, newest.id DESC
+      LIMIT $3
+  )
+`
+
+type DeleteOldAPITokensParams struct {
+	UserID int32
+	Name   string
+	Keep   int32
+}
+
+// Deletes a user's tokens with the given name except the newest keep.
+func (q *Queries) DeleteOldAPITokens(ctx context.Context, arg DeleteOldAPITokensParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteOldAPITokens, arg.UserID, arg.Name, arg.Keep)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const enableFeed = `-- name: EnableFeed :exec
+UPDATE feeds
+SET disabled_at = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) EnableFeed(ctx context.Context, id int32) error {
+	_, err := q.db.ExecContext(ctx, enableFeed, id)
+	return err
+}
+
+const followFeed = `-- name: FollowFeed :one
+INSERT INTO feed_follows (user_id, feed_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, feed_id) DO UPDATE
+SET updated_at = feed_follows.updated_at
+RETURNING id, (xmax = 0) AS inserted
+`
+
+type FollowFeedParams struct {
+	UserID int32
+	FeedID int32
+}
+
+type FollowFeedRow struct {
+	ID       int32
+	Inserted bool
+}
+
+func (q *Queries) FollowFeed(ctx context.Context, arg FollowFeedParams) (FollowFeedRow, error) {
+	row := q.db.QueryRowContext(ctx, followFeed, arg.UserID, arg.FeedID)
+	var i FollowFeedRow
+	err := row.Scan(&i.ID, &i.Inserted)
+	return i, err
+}
+
+const getAPITokensForUser = `-- name: GetAPITokensForUser :many
+SELECT id, created_at, user_id, name, token_hash, last_used_at FROM api_tokens
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetAPITokensForUser(ctx context.Context, userID int32) ([]ApiToken, error) {
+	rows, err := q.db.QueryContext(ctx, getAPITokensForUser, userID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []GetFeedFollowsForUserRow
+	var items []ApiToken
 	for rows.Next() {
-		var i GetFeedFollowsForUserRow
+		var i ApiToken
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
-			&i.UpdatedAt,
 			&i.UserID,
-			&i.FeedID,
-			&i.FeedName,
-			&i.UserName,
+			&i.Name,
+			&i.TokenHash,
+			&i.LastUsedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -221,34 +476,75 @@ func (q *Queries) GetFeedFollowsForUser(ctx context.Context, arg GetFeedFollowsF
 	return items, nil
 }
 
-const getFeeds = `-- name: GetFeeds :many
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-ORDER BY created_at DESC
-LIMIT $1 OFFSET $2
+const getEnclosuresForFeed = `-- name: GetEnclosuresForFeed :many
+SELECT enclosures.id, enclosures.created_at, enclosures.updated_at, enclosures.post_id, enclosures.url, enclosures.mime_type, enclosures.length, enclosures.duration_seconds FROM enclosures
+JOIN posts ON enclosures.post_id = posts.id
+WHERE posts.feed_id = $1
+ORDER BY posts.published_at DESC, enclosures.id
+LIMIT $2
 `
 
-type GetFeedsParams struct {
+type GetEnclosuresForFeedParams struct {
+	FeedID int32
 	Limit  int32
-	Offset int32
 }
 
-func (q *Queries) GetFeeds(ctx context.Context, arg GetFeedsParams) ([]Feed, error) {
-	rows, err := q.db.QueryContext(ctx, getFeeds, arg.Limit, arg.Offset)
+func (q *Queries) GetEnclosuresForFeed(ctx context.Context, arg GetEnclosuresForFeedParams) ([]Enclosure, error) {
+	rows, err := q.db.QueryContext(ctx, getEnclosuresForFeed, arg.FeedID, arg.Limit)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
-	var items []Feed
+	var items []Enclosure
 	for rows.Next() {
-		var i Feed
+		var i Enclosure
 		if err := rows.Scan(
 			&i.ID,
 			&i.CreatedAt,
 			&i.UpdatedAt,
-			&i.Name,
+			&i.PostID,
 			&i.Url,
-			&i.UserID,
-			&i.LastFetchedAt,
+			&i.MimeType,
+			&i.Length,
+			&i.DurationSeconds,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getEnclosuresForPosts = `-- name: GetEnclosuresForPosts :many
+SELECT id, created_at, updated_at, post_id, url, mime_type, length, duration_seconds FROM enclosures
+WHERE post_id = ANY($1::int[])
+ORDER BY post_id, id
+`
+
+func (q *Queries) GetEnclosuresForPosts(ctx context.Context, postIds []int32) ([]Enclosure, error) {
+	rows, err := q.db.QueryContext(ctx, getEnclosuresForPosts, pq.Array(postIds))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Enclosure
+	for rows.Next() {
+		var i Enclosure
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.PostID,
+			&i.Url,
+			&i.MimeType,
+			&i.Length,
+			&i.DurationSeconds,
 		); err != nil {
 			return nil, err
 		}
@@ -263,15 +559,13 @@ func (q *Queries) GetFeeds(ctx context.Context, arg GetFeedsParams) ([]Feed, err
 	return items, nil
 }
 
-const getNextFeedToFetch = `-- name: GetNextFeedToFetch :one
-SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at FROM feeds
-WHERE last_fetched_at IS NULL OR last_fetched_at < NOW() - INTERVAL '1 hour'
-ORDER BY last_fetched_at ASC
-LIMIT 1
+const getFeedByNameOrURL = `-- name: GetFeedByNameOrURL :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, adaptive_schedule, next_fetch_at, consecutive_failures, last_error, last_status, last_success_at, disabled_at, moved_to_url, moved_count, last_scrape_inserted, last_scrape_updated, last_scrape_skipped, last_scrape_errors, retention_max_age_seconds, retention_max_posts, min_interval_seconds, skip_hours, skip_days FROM feeds
+WHERE name = $1 OR url = $1
 `
 
-func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
-	row := q.db.QueryRowContext(ctx, getNextFeedToFetch)
+func (q *Queries) GetFeedByNameOrURL(ctx context.Context, nameOrUrl string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByNameOrURL, nameOrUrl)
 	var i Feed
 	err := row.Scan(
 		&i.ID,
@@ -281,29 +575,655 @@ func (q *Queries) GetNextFeedToFetch(ctx context.Context) (Feed, error) {
 		&i.Url,
 		&i.UserID,
 		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.AdaptiveSchedule,
+		&i.NextFetchAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastStatus,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.MovedToUrl,
+		&i.MovedCount,
+		&i.LastScrapeInserted,
+		&i.LastScrapeUpdated,
+		&i.LastScrapeSkipped,
+		&i.LastScrapeErrors,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+		&i.MinIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
 	)
 	return i, err
 }
 
-const getPostsForUser = `-- name: GetPostsForUser :many
-SELECT
-    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description, posts.published_at, posts.feed_id,
-    feeds.name AS feed_name,
-    users.name AS user_name
-FROM posts
-JOIN feeds ON posts.feed_id = feeds.id
-JOIN users ON feeds.user_id = users.id
-WHERE users.id = $1
-ORDER BY posts.published_at DESC
-LIMIT $2 OFFSET $3
+const getFeedByURL = `-- name: GetFeedByURL :one
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, adaptive_schedule, next_fetch_at, consecutive_failures, last_error, last_status, last_success_at, disabled_at, moved_to_url, moved_count, last_scrape_inserted, last_scrape_updated, last_scrape_skipped, last_scrape_errors, retention_max_age_seconds, retention_max_posts, min_interval_seconds, skip_hours, skip_days FROM feeds
+WHERE url = $1
 `
 
-type GetPostsForUserParams struct {
-	ID     int32
+func (q *Queries) GetFeedByURL(ctx context.Context, url string) (Feed, error) {
+	row := q.db.QueryRowContext(ctx, getFeedByURL, url)
+	var i Feed
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+		&i.Url,
+		&i.UserID,
+		&i.LastFetchedAt,
+		&i.Etag,
+		&i.LastModified,
+		&i.FetchIntervalSeconds,
+		&i.AdaptiveSchedule,
+		&i.NextFetchAt,
+		&i.ConsecutiveFailures,
+		&i.LastError,
+		&i.LastStatus,
+		&i.LastSuccessAt,
+		&i.DisabledAt,
+		&i.MovedToUrl,
+		&i.MovedCount,
+		&i.LastScrapeInserted,
+		&i.LastScrapeUpdated,
+		&i.LastScrapeSkipped,
+		&i.LastScrapeErrors,
+		&i.RetentionMaxAgeSeconds,
+		&i.RetentionMaxPosts,
+		&i.MinIntervalSeconds,
+		pq.Array(&i.SkipHours),
+		pq.Array(&i.SkipDays),
+	)
+	return i, err
+}
+
+const getFeedFollow = `-- name: GetFeedFollow :one
+SELECT id, created_at, updated_at, user_id, feed_id FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2
+`
+
+type GetFeedFollowParams struct {
+	UserID int32
+	FeedID int32
+}
+
+func (q *Queries) GetFeedFollow(ctx context.Context, arg GetFeedFollowParams) (FeedFollow, error) {
+	row := q.db.QueryRowContext(ctx, getFeedFollow, arg.UserID, arg.FeedID)
+	var i FeedFollow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.UserID,
+		&i.FeedID,
+	)
+	return i, err
+}
+
+const getFeedFollowsForUser = `-- name: GetFeedFollowsForUser :many
+SELECT
+    feed_follows.id, feed_follows.created_at, feed_follows.updated_at, feed_follows.user_id, feed_follows.feed_id,
+    feeds.name AS feed_name,
+    users.name AS user_name,
+    (
+        SELECT COUNT(*) FROM posts
+        LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+        WHERE posts.feed_id = feed_follows.feed_id AND post_states.read_at IS NULL
+    ) AS unread_count,
+    COALESCE((
+        SELECT array_agg(folders.name ORDER BY folders.name)
+        FROM feed_follow_folders
+        JOIN folders ON feed_follow_folders.folder_id = folders.id
+        WHERE feed_follow_folders.feed_follow_id = feed_follows.id
+    ), '{}')::text[] AS folders
+FROM feed_follows
+JOIN feeds ON feed_follows.feed_id = feeds.id
+JOIN users ON feed_follows.user_id = users.id
+WHERE feed_follows.user_id = $1
+ORDER BY feed_follows.created_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type GetFeedFollowsForUserParams struct {
+	UserID int32
+	Limit  int32
+	Offset int32
+}
+
+type GetFeedFollowsForUserRow struct {
+	ID          int32
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	UserID      int32
+	FeedID      int32
+	FeedName    string
+	UserName    string
+	UnreadCount int64
+	Folders     []string
+}
+
+func (q *Queries) GetFeedFollowsForUser(ctx context.Context, arg GetFeedFollowsForUserParams) ([]GetFeedFollowsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedFollowsForUser, arg.UserID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedFollowsForUserRow
+	for rows.Next() {
+		var i GetFeedFollowsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.UserID,
+			&i.FeedID,
+			&i.FeedName,
+			&i.UserName,
+			&i.UnreadCount,
+			pq.Array(&i.Folders),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeedRetentions = `-- name: GetFeedRetentions :many
+SELECT id, name, retention_max_age_seconds, retention_max_posts FROM feeds
+ORDER BY name
+`
+
+type GetFeedRetentionsRow struct {
+	ID                     int32
+	Name                   string
+	RetentionMaxAgeSeconds sql.NullInt32
+	RetentionMaxPosts      sql.NullInt32
+}
+
+func (q *Queries) GetFeedRetentions(ctx context.Context) ([]GetFeedRetentionsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFeedRetentions)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFeedRetentionsRow
+	for rows.Next() {
+		var i GetFeedRetentionsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Name,
+			&i.RetentionMaxAgeSeconds,
+			&i.RetentionMaxPosts,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFeeds = `-- name: GetFeeds :many
+SELECT id, created_at, updated_at, name, url, user_id, last_fetched_at, etag, last_modified, fetch_interval_seconds, adaptive_schedule, next_fetch_at, consecutive_failures, last_error, last_status, last_success_at, disabled_at, moved_to_url, moved_count, last_scrape_inserted, last_scrape_updated, last_scrape_skipped, last_scrape_errors, retention_max_age_seconds, retention_max_posts, min_interval_seconds, skip_hours, skip_days FROM feeds
+ORDER BY created_at DESC
+LIMIT $1 OFFSET $2
+`
+
+type GetFeedsParams struct {
 	Limit  int32
 	Offset int32
 }
 
+func (q *Queries) GetFeeds(ctx context.Context, arg GetFeedsParams) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFeeds, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.FetchIntervalSeconds,
+			&i.AdaptiveSchedule,
+			&i.NextFetchAt,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastStatus,
+			&i.LastSuccessAt,
+			&i.DisabledAt,
+			&i.MovedToUrl,
+			&i.MovedCount,
+			&i.LastScrapeInserted,
+			&i.LastScrapeUpdated,
+			&i.LastScrapeSkipped,
+			&i.LastScrapeErrors,
+			&i.RetentionMaxAgeSeconds,
+			&i.RetentionMaxPosts,
+			&i.MinIntervalSeconds,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFolderFeedsForUser = `-- name: GetFolderFeedsForUser :many
+SELECT feed_follow_folders.folder_id, feed_follows.feed_id
+FROM feed_follow_folders
+JOIN feed_follows ON feed_follow_folders.feed_follow_id = feed_follows.id
+WHERE feed_follows.user_id = $1
+ORDER BY feed_follow_folders.folder_id, feed_follows.feed_id
+`
+
+type GetFolderFeedsForUserRow struct {
+	FolderID int32
+	FeedID   int32
+}
+
+func (q *Queries) GetFolderFeedsForUser(ctx context.Context, userID int32) ([]GetFolderFeedsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFolderFeedsForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFolderFeedsForUserRow
+	for rows.Next() {
+		var i GetFolderFeedsForUserRow
+		if err := rows.Scan(&i.FolderID, &i.FeedID); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFoldersForUser = `-- name: GetFoldersForUser :many
+SELECT
+    folders.id,
+    folders.name,
+    COUNT(feed_follow_folders.feed_follow_id) AS feed_count
+FROM folders
+LEFT JOIN feed_follow_folders ON feed_follow_folders.folder_id = folders.id
+WHERE folders.user_id = $1
+GROUP BY folders.id
+ORDER BY folders.name
+`
+
+type GetFoldersForUserRow struct {
+	ID        int32
+	Name      string
+	FeedCount int64
+}
+
+func (q *Queries) GetFoldersForUser(ctx context.Context, userID int32) ([]GetFoldersForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFoldersForUser, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFoldersForUserRow
+	for rows.Next() {
+		var i GetFoldersForUserRow
+		if err := rows.Scan(&i.ID, &i.Name, &i.FeedCount); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFollowedFeeds = `-- name: GetFollowedFeeds :many
+SELECT feeds.id, feeds.created_at, feeds.updated_at, feeds.name, feeds.url, feeds.user_id, feeds.last_fetched_at, feeds.etag, feeds.last_modified, feeds.fetch_interval_seconds, feeds.adaptive_schedule, feeds.next_fetch_at, feeds.consecutive_failures, feeds.last_error, feeds.last_status, feeds.last_success_at, feeds.disabled_at, feeds.moved_to_url, feeds.moved_count, feeds.last_scrape_inserted, feeds.last_scrape_updated, feeds.last_scrape_skipped, feeds.last_scrape_errors, feeds.retention_max_age_seconds, feeds.retention_max_posts, feeds.min_interval_seconds, feeds.skip_hours, feeds.skip_days FROM feeds
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.name
+`
+
+func (q *Queries) GetFollowedFeeds(ctx context.Context, userID int32) ([]Feed, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedFeeds, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Feed
+	for rows.Next() {
+		var i Feed
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Name,
+			&i.Url,
+			&i.UserID,
+			&i.LastFetchedAt,
+			&i.Etag,
+			&i.LastModified,
+			&i.FetchIntervalSeconds,
+			&i.AdaptiveSchedule,
+			&i.NextFetchAt,
+			&i.ConsecutiveFailures,
+			&i.LastError,
+			&i.LastStatus,
+			&i.LastSuccessAt,
+			&i.DisabledAt,
+			&i.MovedToUrl,
+			&i.MovedCount,
+			&i.LastScrapeInserted,
+			&i.LastScrapeUpdated,
+			&i.LastScrapeSkipped,
+			&i.LastScrapeErrors,
+			&i.RetentionMaxAgeSeconds,
+			&i.RetentionMaxPosts,
+			&i.MinIntervalSeconds,
+			pq.Array(&i.SkipHours),
+			pq.Array(&i.SkipDays),
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getFollowedFeedsWithFolders = `-- name: GetFollowedFeedsWithFolders :many
+SELECT
+    feeds.name,
+    feeds.url,
+    COALESCE(
+        array_agg(folders.name ORDER BY folders.name) FILTER (WHERE folders.name IS NOT NULL),
+        '{}'
+    )::text[] AS folders
+FROM feed_follows
+JOIN feeds ON feed_follows.feed_id = feeds.id
+LEFT JOIN feed_follow_folders ON feed_follow_folders.feed_follow_id = feed_follows.id
+LEFT JOIN folders ON feed_follow_folders.folder_id = folders.id
+WHERE feed_follows.user_id = $1
+GROUP BY feeds.id
+ORDER BY feeds.name
+`
+
+type GetFollowedFeedsWithFoldersRow struct {
+	Name    string
+	Url     string
+	Folders []string
+}
+
+func (q *Queries) GetFollowedFeedsWithFolders(ctx context.Context, userID int32) ([]GetFollowedFeedsWithFoldersRow, error) {
+	rows, err := q.db.QueryContext(ctx, getFollowedFeedsWithFolders, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetFollowedFeedsWithFoldersRow
+	for rows.Next() {
+		var i GetFollowedFeedsWithFoldersRow
+		if err := rows.Scan(&i.Name, &i.Url, pq.Array(&i.Folders)); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostForUser = `-- name: GetPostForUser :one
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description,
+    posts.published_at, posts.feed_id, posts.guid, posts.content, posts.author, posts.comments_url,
+    posts.categories,
+    feeds.name AS feed_name,
+    post_states.read_at,
+    post_states.starred_at
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE posts.id = $1 AND feed_follows.user_id = $2
+`
+
+type GetPostForUserParams struct {
+	ID     int32
+	UserID int32
+}
+
+type GetPostForUserRow struct {
+	ID          int32
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedID      int32
+	Guid        string
+	Content     sql.NullString
+	Author      sql.NullString
+	CommentsUrl sql.NullString
+	Categories  []string
+	FeedName    string
+	ReadAt      sql.NullTime
+	StarredAt   sql.NullTime
+}
+
+func (q *Queries) GetPostForUser(ctx context.Context, arg GetPostForUserParams) (GetPostForUserRow, error) {
+	row := q.db.QueryRowContext(ctx, getPostForUser, arg.ID, arg.UserID)
+	var i GetPostForUserRow
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Title,
+		&i.Url,
+		&i.Description,
+		&i.PublishedAt,
+		&i.FeedID,
+		&i.Guid,
+		&i.Content,
+		&i.Author,
+		&i.CommentsUrl,
+		pq.Array(&i.Categories),
+		&i.FeedName,
+		&i.ReadAt,
+		&i.StarredAt,
+	)
+	return i, err
+}
+
+const getPostsByIDForUser = `-- name: GetPostsByIDForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description,
+    posts.published_at, posts.feed_id, posts.guid, posts.content, posts.author, posts.comments_url,
+    posts.categories,
+    feeds.name AS feed_name,
+    post_states.read_at,
+    post_states.starred_at
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+  AND ($2::int[] IS NULL OR posts.id = ANY($2::int[]))
+  AND ($3::int IS NULL OR posts.id > $3)
+  AND ($4::int IS NULL OR posts.id < $4)
+ORDER BY CASE WHEN $4::int IS NULL THEN posts.id ELSE -posts.id END
+LIMIT $5
+`
+
+type GetPostsByIDForUserParams struct {
+	UserID  int32
+	Ids     []int32
+	SinceID sql.NullInt32
+	MaxID   sql.NullInt32
+	Limit   int32
+}
+
+type GetPostsByIDForUserRow struct {
+	ID          int32
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedID      int32
+	Guid        string
+	Content     sql.NullString
+	Author      sql.NullString
+	CommentsUrl sql.NullString
+	Categories  []string
+	FeedName    string
+	ReadAt      sql.NullTime
+	StarredAt   sql.NullTime
+}
+
+// Lists posts of the user's followed feeds by ID: those with the given IDs,
+// or else those after since_id in ascending order, or else those before
+// max_id in descending order.
+func (q *Queries) GetPostsByIDForUser(ctx context.Context, arg GetPostsByIDForUserParams) ([]GetPostsByIDForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getPostsByIDForUser,
+		arg.UserID,
+		pq.Array(arg.Ids),
+		arg.SinceID,
+		arg.MaxID,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetPostsByIDForUserRow
+	for rows.Next() {
+		var i GetPostsByIDForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Guid,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			pq.Array(&i.Categories),
+			&i.FeedName,
+			&i.ReadAt,
+			&i.StarredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getPostsForUser = `-- name: GetPostsForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description,
+    posts.published_at, posts.feed_id, posts.guid, posts.content, posts.author, posts.comments_url,
+    posts.categories,
+    feeds.name AS feed_name,
+    post_states.read_at,
+    post_states.starred_at
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+  AND ($2::text IS NULL OR feeds.name = $2 OR feeds.url = $2)
+  AND ($3::text IS NULL OR posts.author ILIKE '%' || $3 || '%')
+  AND ($4::text IS NULL OR EXISTS (
+      SELECT 1 FROM unnest(posts.categories) AS category
+      WHERE lower(category) = lower($4)
+  ))
+  AND ($5::text IS NULL OR EXISTS (
+      SELECT 1 FROM feed_follow_folders
+      JOIN folders ON feed_follow_folders.folder_id = folders.id
+      WHERE feed_follow_folders.feed_follow_id = feed_follows.id
+        AND (folders.name = $5 OR left(folders.name, length($5) + 1) = $5 || '/')
+  ))
+  AND (NOT $6::bool OR post_states.read_at IS NULL)
+ORDER BY posts.published_at DESC
+LIMIT $7 OFFSET $8
+`
+
+type GetPostsForUserParams struct {
+	UserID     int32
+	Feed       sql.NullString
+	Author     sql.NullString
+	Category   sql.NullString
+	Folder     sql.NullString
+	UnreadOnly bool
+	Limit      int32
+	Offset     int32
+}
+
 type GetPostsForUserRow struct {
 	ID          int32
 	CreatedAt   time.Time
@@ -313,12 +1233,27 @@ type GetPostsForUserRow struct {
 	Description sql.NullString
 	PublishedAt time.Time
 	FeedID      int32
+	Guid        string
+	Content     sql.NullString
+	Author      sql.NullString
+	CommentsUrl sql.NullString
+	Categories  []string
 	FeedName    string
-	UserName    string
+	ReadAt      sql.NullTime
+	StarredAt   sql.NullTime
 }
 
 func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams) ([]GetPostsForUserRow, error) {
-	rows, err := q.db.QueryContext(ctx, getPostsForUser, arg.ID, arg.Limit, arg.Offset)
+	rows, err := q.db.QueryContext(ctx, getPostsForUser,
+		arg.UserID,
+		arg.Feed,
+		arg.Author,
+		arg.Category,
+		arg.Folder,
+		arg.UnreadOnly,
+		arg.Limit,
+		arg.Offset,
+	)
 	if err != nil {
 		return nil, err
 	}
@@ -335,8 +1270,14 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 			&i.Description,
 			&i.PublishedAt,
 			&i.FeedID,
+			&i.Guid,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			pq.Array(&i.Categories),
 			&i.FeedName,
-			&i.UserName,
+			&i.ReadAt,
+			&i.StarredAt,
 		); err != nil {
 			return nil, err
 		}
@@ -351,13 +1292,271 @@ func (q *Queries) GetPostsForUser(ctx context.Context, arg GetPostsForUserParams
 	return items, nil
 }
 
+const getStarredPostIDs = `-- name: GetStarredPostIDs :many
+SELECT post_states.post_id FROM post_states
+JOIN posts ON post_states.post_id = posts.id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = post_states.user_id
+WHERE post_states.user_id = $1 AND post_states.starred_at IS NOT NULL
+ORDER BY post_states.post_id
+`
+
+func (q *Queries) GetStarredPostIDs(ctx context.Context, userID int32) ([]int32, error) {
+	rows, err := q.db.QueryContext(ctx, getStarredPostIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var post_id int32
+		if err := rows.Scan(&post_id); err != nil {
+			return nil, err
+		}
+		items = append(items, post_id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStarredPostsForUser = `-- name: GetStarredPostsForUser :many
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description,
+    posts.published_at, posts.feed_id, posts.guid, posts.content, posts.author, posts.comments_url,
+    posts.categories,
+    feeds.name AS feed_name,
+    post_states.starred_at
+FROM post_states
+JOIN posts ON post_states.post_id = posts.id
+JOIN feeds ON posts.feed_id = feeds.id
+WHERE post_states.user_id = $1 AND post_states.starred_at IS NOT NULL
+ORDER BY post_states.starred_at DESC
+LIMIT $2 OFFSET $3
+`
+
+type GetStarredPostsForUserParams struct {
+	UserID int32
+	Limit  int32
+	Offset int32
+}
+
+type GetStarredPostsForUserRow struct {
+	ID          int32
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedID      int32
+	Guid        string
+	Content     sql.NullString
+	Author      sql.NullString
+	CommentsUrl sql.NullString
+	Categories  []string
+	FeedName    string
+	StarredAt   sql.NullTime
+}
+
+// Starred posts stay listed after their feed is unfollowed, so this
+// doesn't join feed_follows.
+func (q *Queries) GetStarredPostsForUser(ctx context.Context, arg GetStarredPostsForUserParams) ([]GetStarredPostsForUserRow, error) {
+	rows, err := q.db.QueryContext(ctx, getStarredPostsForUser, arg.UserID, arg.Limit, arg.Offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetStarredPostsForUserRow
+	for rows.Next() {
+		var i GetStarredPostsForUserRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Title,
+			&i.Url,
+			&i.Description,
+			&i.PublishedAt,
+			&i.FeedID,
+			&i.Guid,
+			&i.Content,
+			&i.Author,
+			&i.CommentsUrl,
+			pq.Array(&i.Categories),
+			&i.FeedName,
+			&i.StarredAt,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getStreamPostIDs = `-- name: GetStreamPostIDs :many
+SELECT posts.id FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1
+  AND ($2::int IS NULL OR posts.feed_id = $2)
+  AND ($3::text IS NULL OR EXISTS (
+      SELECT 1 FROM feed_follow_folders
+      JOIN folders ON feed_follow_folders.folder_id = folders.id
+      WHERE feed_follow_folders.feed_follow_id = feed_follows.id
+        AND (folders.name = $3 OR left(folders.name, length($3) + 1) = $3 || '/')
+  ))
+  AND (NOT $4::bool OR post_states.starred_at IS NOT NULL)
+  AND ($5::bool IS NULL OR (post_states.read_at IS NOT NULL) = $5)
+  AND ($6::timestamp IS NULL OR posts.published_at >= $6)
+  AND ($7::timestamp IS NULL OR posts.published_at < $7)
+ORDER BY
+    CASE WHEN $8::bool THEN posts.published_at END,
+    posts.published_at DESC,
+    posts.id DESC
+LIMIT $9 OFFSET $10
+`
+
+type GetStreamPostIDsParams struct {
+	UserID          int32
+	FeedID          sql.NullInt32
+	Folder          sql.NullString
+	StarredOnly     bool
+	Read            sql.NullBool
+	PublishedAfter  sql.NullTime
+	PublishedBefore sql.NullTime
+	OldestFirst     bool
+	Limit           int32
+	Offset          int32
+}
+
+// Lists the IDs of posts in the user's followed feeds for a Google Reader
+// stream, optionally limited to one feed or folder, to starred posts, to
+// read or unread posts and to a range of publication times. Posts are
+// listed newest first unless oldest_first is set.
+func (q *Queries) GetStreamPostIDs(ctx context.Context, arg GetStreamPostIDsParams) ([]int32, error) {
+	rows, err := q.db.QueryContext(ctx, getStreamPostIDs,
+		arg.UserID,
+		arg.FeedID,
+		arg.Folder,
+		arg.StarredOnly,
+		arg.Read,
+		arg.PublishedAfter,
+		arg.PublishedBefore,
+		arg.OldestFirst,
+		arg.Limit,
+		arg.Offset,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnreadPostIDs = `-- name: GetUnreadPostIDs :many
+SELECT posts.id FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND post_states.read_at IS NULL
+ORDER BY posts.id
+`
+
+func (q *Queries) GetUnreadPostIDs(ctx context.Context, userID int32) ([]int32, error) {
+	rows, err := q.db.QueryContext(ctx, getUnreadPostIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []int32
+	for rows.Next() {
+		var id int32
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		items = append(items, id)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getUser = `-- name: GetUser :one
 SELECT id, created_at, updated_at, name FROM users
 WHERE name = $1
 `
 
-func (q *Queries) GetUser(ctx context.Context, name string) (User, error) {
-	row := q.db.QueryRowContext(ctx, getUser, name)
+func (q *Queries) GetUser(ctx context.Context, name string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUser, name)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+	)
+	return i, err
+}
+
+const getUserByAPIToken = `-- name: GetUserByAPIToken :one
+WITH token AS (
+    UPDATE api_tokens
+    SET last_used_at = NOW()
+    WHERE token_hash = $1
+    RETURNING user_id
+)
+SELECT users.id, users.created_at, users.updated_at, users.name FROM users
+JOIN token ON token.user_id = users.id
+`
+
+// Finds the user a token belongs to and records that the token was used.
+func (q *Queries) GetUserByAPIToken(ctx context.Context, tokenHash string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByAPIToken, tokenHash)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Name,
+	)
+	return i, err
+}
+
+const getUserByFeverKey = `-- name: GetUserByFeverKey :one
+SELECT users.id, users.created_at, users.updated_at, users.name FROM users
+JOIN fever_keys ON fever_keys.user_id = users.id
+WHERE fever_keys.key_hash = $1
+`
+
+func (q *Queries) GetUserByFeverKey(ctx context.Context, keyHash string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByFeverKey, keyHash)
 	var i User
 	err := row.Scan(
 		&i.ID,
@@ -424,15 +1623,270 @@ func (q *Queries) GetUsers(ctx context.Context, arg GetUsersParams) ([]User, err
 	return items, nil
 }
 
-const lastFetchedAt = `-- name: LastFetchedAt :exec
+const markFolderRead = `-- name: MarkFolderRead :execrows
+INSERT INTO post_states (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, NOW()
+FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+JOIN feed_follow_folders ON feed_follow_folders.feed_follow_id = feed_follows.id
+WHERE feed_follows.user_id = $1
+  AND feed_follow_folders.folder_id = $2
+  AND ($3::timestamp IS NULL OR posts.published_at < $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = NOW(),
+    updated_at = NOW()
+WHERE post_states.read_at IS NULL
+`
+
+type MarkFolderReadParams struct {
+	UserID   int32
+	FolderID int32
+	Before   sql.NullTime
+}
+
+// Marks the user's unread posts in the feeds of one folder as read,
+// limited to posts published before a time when one is given.
+func (q *Queries) MarkFolderRead(ctx context.Context, arg MarkFolderReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markFolderRead, arg.UserID, arg.FolderID, arg.Before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markPostRead = `-- name: MarkPostRead :exec
+INSERT INTO post_states (user_id, post_id, read_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = COALESCE(post_states.read_at, NOW()),
+    updated_at = NOW()
+`
+
+type MarkPostReadParams struct {
+	UserID int32
+	PostID int32
+}
+
+func (q *Queries) MarkPostRead(ctx context.Context, arg MarkPostReadParams) error {
+	_, err := q.db.ExecContext(ctx, markPostRead, arg.UserID, arg.PostID)
+	return err
+}
+
+const markPostUnread = `-- name: MarkPostUnread :execrows
+UPDATE post_states
+SET read_at = NULL,
+    updated_at = NOW()
+WHERE user_id = $1 AND post_id = $2 AND read_at IS NOT NULL
+`
+
+type MarkPostUnreadParams struct {
+	UserID int32
+	PostID int32
+}
+
+func (q *Queries) MarkPostUnread(ctx context.Context, arg MarkPostUnreadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markPostUnread, arg.UserID, arg.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const markPostsRead = `-- name: MarkPostsRead :execrows
+INSERT INTO post_states (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, NOW()
+FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = $1
+  AND ($2::int IS NULL OR posts.feed_id = $2)
+  AND ($3::timestamp IS NULL OR posts.published_at < $3)
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = NOW(),
+    updated_at = NOW()
+WHERE post_states.read_at IS NULL
+`
+
+type MarkPostsReadParams struct {
+	UserID int32
+	FeedID sql.NullInt32
+	Before sql.NullTime
+}
+
+// Marks the user's unread posts as read, limited to one feed and to posts
+// published before a time when those are given.
+func (q *Queries) MarkPostsRead(ctx context.Context, arg MarkPostsReadParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, markPostsRead, arg.UserID, arg.FeedID, arg.Before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const pruneExcessPosts = `-- name: PruneExcessPosts :execrows
+DELETE FROM posts
+WHERE id IN (
+    SELECT older.id FROM (
+        SELECT posts.id FROM posts
+        WHERE posts.feed_id = $1
+        ORDER BY posts.published_at DESC, posts.id DESC
+        OFFSET $2
+    ) AS older
+    WHERE NOT EXISTS (
+        SELECT 1 FROM post_states
+        WHERE post_states.post_id = older.id
+          AND post_states.starred_at IS NOT NULL
+    )
+    LIMIT $3
+)
+`
+
+type PruneExcessPostsParams struct {
+	FeedID    int32
+	Keep      int32
+	BatchSize int32
+}
+
+// Deletes up to batch_size posts of a feed beyond its newest keep posts,
+// skipping posts anyone has starred. Starred posts still count towards
+// the posts kept.
+func (q *Queries) PruneExcessPosts(ctx context.Context, arg PruneExcessPostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneExcessPosts, arg.FeedID, arg.Keep, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const pruneExpiredPosts = `-- name: PruneExpiredPosts :execrows
+DELETE FROM posts
+WHERE id IN (
+    SELECT posts.id FROM posts
+    WHERE posts.feed_id = $1
+      AND posts.published_at < $2
+      AND NOT EXISTS (
+          SELECT 1 FROM post_states
+          WHERE post_states.post_id = posts.id
+            AND post_states.starred_at IS NOT NULL
+      )
+    LIMIT $3
+)
+`
+
+type PruneExpiredPostsParams struct {
+	FeedID    int32
+	Before    time.Time
+	BatchSize int32
+}
+
+// Deletes up to batch_size posts of a feed published before a time,
+// skipping posts anyone has starred.
+func (q *Queries) PruneExpiredPosts(ctx context.Context, arg PruneExpiredPostsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, pruneExpiredPosts, arg.FeedID, arg.Before, arg.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const recordFeedFailure = `-- name: RecordFeedFailure :one
 UPDATE feeds
-SET last_fetched_at = NOW(),
+SET consecutive_failures = consecutive_failures + 1,
+    last_error = $1,
+    last_status = $2,
+    next_fetch_at = NOW() + make_interval(secs => $3::int),
+    disabled_at = CASE
+        WHEN consecutive_failures + 1 >= $4::int THEN NOW()
+        ELSE disabled_at
+    END,
+    updated_at = NOW()
+WHERE id = $5
+RETURNING consecutive_failures, disabled_at
+`
+
+type RecordFeedFailureParams struct {
+	LastError      sql.NullString
+	LastStatus     sql.NullInt32
+	BackoffSeconds int32
+	MaxFailures    int32
+	ID             int32
+}
+
+type RecordFeedFailureRow struct {
+	ConsecutiveFailures int32
+	DisabledAt          sql.NullTime
+}
+
+func (q *Queries) RecordFeedFailure(ctx context.Context, arg RecordFeedFailureParams) (RecordFeedFailureRow, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedFailure,
+		arg.LastError,
+		arg.LastStatus,
+		arg.BackoffSeconds,
+		arg.MaxFailures,
+		arg.ID,
+	)
+	var i RecordFeedFailureRow
+	err := row.Scan(&i.ConsecutiveFailures, &i.DisabledAt)
+	return i, err
+}
+
+const recordFeedMove = `-- name: RecordFeedMove :one
+UPDATE feeds
+SET moved_count = CASE
+        WHEN moved_to_url = $1 AND moved_count = 0 THEN 0
+        WHEN moved_to_url = $1 THEN moved_count + 1
+        ELSE 1
+    END,
+    moved_to_url = $1
+WHERE id = $2
+RETURNING moved_count
+`
+
+type RecordFeedMoveParams struct {
+	MovedToUrl sql.NullString
+	ID         int32
+}
+
+// A move blocked by BlockFeedMove keeps a count of 0 for as long as the
+// feed redirects to the same URL.
+func (q *Queries) RecordFeedMove(ctx context.Context, arg RecordFeedMoveParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, recordFeedMove, arg.MovedToUrl, arg.ID)
+	var moved_count int32
+	err := row.Scan(&moved_count)
+	return moved_count, err
+}
+
+const recordFeedSuccess = `-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET consecutive_failures = 0,
+    last_error = NULL,
+    last_status = $2,
+    last_success_at = NOW(),
+    last_scrape_inserted = $3,
+    last_scrape_updated = $4,
+    last_scrape_skipped = $5,
+    last_scrape_errors = $6,
     updated_at = NOW()
 WHERE id = $1
 `
 
-func (q *Queries) LastFetchedAt(ctx context.Context, id int32) error {
-	_, err := q.db.ExecContext(ctx, lastFetchedAt, id)
+type RecordFeedSuccessParams struct {
+	ID                 int32
+	LastStatus         sql.NullInt32
+	LastScrapeInserted int32
+	LastScrapeUpdated  int32
+	LastScrapeSkipped  int32
+	LastScrapeErrors   sql.NullString
+}
+
+func (q *Queries) RecordFeedSuccess(ctx context.Context, arg RecordFeedSuccessParams) error {
+	_, err := q.db.ExecContext(ctx, recordFeedSuccess,
+		arg.ID,
+		arg.LastStatus,
+		arg.LastScrapeInserted,
+		arg.LastScrapeUpdated,
+		arg.LastScrapeSkipped,
+		arg.LastScrapeErrors,
+	)
 	return err
 }
 
@@ -451,6 +1905,29 @@ func (q *Queries) RemoveFeedFollow(ctx context.Context, arg RemoveFeedFollowPara
 	return err
 }
 
+const removeFeedFollowFromFolder = `-- name: RemoveFeedFollowFromFolder :execrows
+DELETE FROM feed_follow_folders
+USING folders
+WHERE feed_follow_folders.folder_id = folders.id
+  AND feed_follow_folders.feed_follow_id = $1
+  AND folders.user_id = $2
+  AND folders.name = $3
+`
+
+type RemoveFeedFollowFromFolderParams struct {
+	FeedFollowID int32
+	UserID       int32
+	Name         string
+}
+
+func (q *Queries) RemoveFeedFollowFromFolder(ctx context.Context, arg RemoveFeedFollowFromFolderParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, removeFeedFollowFromFolder, arg.FeedFollowID, arg.UserID, arg.Name)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const reset = `-- name: Reset :exec
 DELETE FROM users
 `
@@ -459,3 +1936,358 @@ func (q *Queries) Reset(ctx context.Context) error {
 	_, err := q.db.ExecContext(ctx, reset)
 	return err
 }
+
+const scheduleNextFetch = `-- name: ScheduleNextFetch :exec
+UPDATE feeds
+SET next_fetch_at = NOW() + make_interval(secs => $1::int),
+    min_interval_seconds = $2,
+    skip_hours = $3::int[],
+    skip_days = $4::int[]
+WHERE id = $5
+`
+
+type ScheduleNextFetchParams struct {
+	DelaySeconds       int32
+	MinIntervalSeconds sql.NullInt32
+	SkipHours          []int32
+	SkipDays           []int32
+	ID                 int32
+}
+
+// The delay is added to the database's clock, so the schedule doesn't
+// depend on the time zone of the host running agg. The publisher's hints
+// are kept for scheduling after 304 responses, which don't repeat them.
+func (q *Queries) ScheduleNextFetch(ctx context.Context, arg ScheduleNextFetchParams) error {
+	_, err := q.db.ExecContext(ctx, scheduleNextFetch,
+		arg.DelaySeconds,
+		arg.MinIntervalSeconds,
+		pq.Array(arg.SkipHours),
+		pq.Array(arg.SkipDays),
+		arg.ID,
+	)
+	return err
+}
+
+const searchPosts = `-- name: SearchPosts :many
+SELECT
+    posts.id, posts.title, posts.url, posts.published_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, query)::real AS rank,
+    ts_headline(
+        'english',
+        coalesce(posts.content, posts.description, posts.title),
+        query,
+        'StartSel=**, StopSel=**, MaxFragments=2, MaxWords=25, MinWords=10, FragmentDelimiter=" ... "'
+    )::text AS snippet
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id,
+    websearch_to_tsquery('english', $1) AS query
+WHERE feed_follows.user_id = $2
+  AND posts.search_vector @@ query
+  AND ($3::text IS NULL OR EXISTS (
+      SELECT 1 FROM feed_follow_folders
+      JOIN folders ON feed_follow_folders.folder_id = folders.id
+      WHERE feed_follow_folders.feed_follow_id = feed_follows.id
+        AND (folders.name = $3 OR left(folders.name, length($3) + 1) = $3 || '/')
+  ))
+ORDER BY rank DESC, posts.published_at DESC
+LIMIT $4
+`
+
+type SearchPostsParams struct {
+	Query  string
+	UserID int32
+	Folder sql.NullString
+	Limit  int32
+}
+
+type SearchPostsRow struct {
+	ID          int32
+	Title       string
+	Url         string
+	PublishedAt time.Time
+	FeedName    string
+	Rank        float32
+	Snippet     string
+}
+
+// Ranks the user's posts matching a web search style query (quoted
+// phrases, OR, -excluded) and highlights the matches in a snippet.
+func (q *Queries) SearchPosts(ctx context.Context, arg SearchPostsParams) ([]SearchPostsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchPosts,
+		arg.Query,
+		arg.UserID,
+		arg.Folder,
+		arg.Limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchPostsRow
+	for rows.Next() {
+		var i SearchPostsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.Title,
+			&i.Url,
+			&i.PublishedAt,
+			&i.FeedName,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const setFeedRetention = `-- name: SetFeedRetention :exec
+UPDATE feeds
+SET retention_max_age_seconds = $2,
+    retention_max_posts = $3,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedRetentionParams struct {
+	ID                     int32
+	RetentionMaxAgeSeconds sql.NullInt32
+	RetentionMaxPosts      sql.NullInt32
+}
+
+func (q *Queries) SetFeedRetention(ctx context.Context, arg SetFeedRetentionParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedRetention, arg.ID, arg.RetentionMaxAgeSeconds, arg.RetentionMaxPosts)
+	return err
+}
+
+const setFeedSchedule = `-- name: SetFeedSchedule :exec
+UPDATE feeds
+SET fetch_interval_seconds = $2,
+    adaptive_schedule = $3,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type SetFeedScheduleParams struct {
+	ID                   int32
+	FetchIntervalSeconds sql.NullInt32
+	AdaptiveSchedule     bool
+}
+
+func (q *Queries) SetFeedSchedule(ctx context.Context, arg SetFeedScheduleParams) error {
+	_, err := q.db.ExecContext(ctx, setFeedSchedule, arg.ID, arg.FetchIntervalSeconds, arg.AdaptiveSchedule)
+	return err
+}
+
+const setFeverKey = `-- name: SetFeverKey :exec
+INSERT INTO fever_keys (user_id, key_hash)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET key_hash = EXCLUDED.key_hash,
+    updated_at = NOW()
+`
+
+type SetFeverKeyParams struct {
+	UserID  int32
+	KeyHash string
+}
+
+func (q *Queries) SetFeverKey(ctx context.Context, arg SetFeverKeyParams) error {
+	_, err := q.db.ExecContext(ctx, setFeverKey, arg.UserID, arg.KeyHash)
+	return err
+}
+
+const starPost = `-- name: StarPost :exec
+INSERT INTO post_states (user_id, post_id, starred_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET starred_at = COALESCE(post_states.starred_at, NOW()),
+    updated_at = NOW()
+`
+
+type StarPostParams struct {
+	UserID int32
+	PostID int32
+}
+
+func (q *Queries) StarPost(ctx context.Context, arg StarPostParams) error {
+	_, err := q.db.ExecContext(ctx, starPost, arg.UserID, arg.PostID)
+	return err
+}
+
+const unstarPost = `-- name: UnstarPost :execrows
+UPDATE post_states
+SET starred_at = NULL,
+    updated_at = NOW()
+WHERE user_id = $1 AND post_id = $2 AND starred_at IS NOT NULL
+`
+
+type UnstarPostParams struct {
+	UserID int32
+	PostID int32
+}
+
+// Starred posts outlive unfollowing, so unstarring doesn't need the post's
+// feed to be followed.
+func (q *Queries) UnstarPost(ctx context.Context, arg UnstarPostParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, unstarPost, arg.UserID, arg.PostID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const updateFeedURL = `-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET url = $2,
+    moved_to_url = NULL,
+    moved_count = 0,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedURLParams struct {
+	ID  int32
+	Url string
+}
+
+func (q *Queries) UpdateFeedURL(ctx context.Context, arg UpdateFeedURLParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedURL, arg.ID, arg.Url)
+	return err
+}
+
+const updateFeedValidators = `-- name: UpdateFeedValidators :exec
+UPDATE feeds
+SET etag = $2,
+    last_modified = $3,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateFeedValidatorsParams struct {
+	ID           int32
+	Etag         sql.NullString
+	LastModified sql.NullString
+}
+
+func (q *Queries) UpdateFeedValidators(ctx context.Context, arg UpdateFeedValidatorsParams) error {
+	_, err := q.db.ExecContext(ctx, updateFeedValidators, arg.ID, arg.Etag, arg.LastModified)
+	return err
+}
+
+const upsertEnclosure = `-- name: UpsertEnclosure :exec
+INSERT INTO enclosures (post_id, url, mime_type, length, duration_seconds)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (post_id, url) DO UPDATE
+SET mime_type = EXCLUDED.mime_type,
+    length = EXCLUDED.length,
+    duration_seconds = EXCLUDED.duration_seconds,
+    updated_at = NOW()
+`
+
+type UpsertEnclosureParams struct {
+	PostID          int32
+	Url             string
+	MimeType        sql.NullString
+	Length          sql.NullInt64
+	DurationSeconds sql.NullInt32
+}
+
+func (q *Queries) UpsertEnclosure(ctx context.Context, arg UpsertEnclosureParams) error {
+	_, err := q.db.ExecContext(ctx, upsertEnclosure,
+		arg.PostID,
+		arg.Url,
+		arg.MimeType,
+		arg.Length,
+		arg.DurationSeconds,
+	)
+	return err
+}
+
+const upsertFolder = `-- name: UpsertFolder :one
+INSERT INTO folders (user_id, name)
+VALUES ($1, $2)
+ON CONFLICT (user_id, name) DO UPDATE
+SET updated_at = folders.updated_at
+RETURNING id
+`
+
+type UpsertFolderParams struct {
+	UserID int32
+	Name   string
+}
+
+func (q *Queries) UpsertFolder(ctx context.Context, arg UpsertFolderParams) (int32, error) {
+	row := q.db.QueryRowContext(ctx, upsertFolder, arg.UserID, arg.Name)
+	var id int32
+	err := row.Scan(&id)
+	return id, err
+}
+
+const upsertPost = `-- name: UpsertPost :one
+INSERT INTO posts (title, url, description, published_at, feed_id, guid, content, author, comments_url, categories)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    content = EXCLUDED.content,
+    author = EXCLUDED.author,
+    comments_url = EXCLUDED.comments_url,
+    categories = EXCLUDED.categories,
+    updated_at = NOW()
+WHERE posts.title IS DISTINCT FROM EXCLUDED.title
+   OR posts.url IS DISTINCT FROM EXCLUDED.url
+   OR posts.description IS DISTINCT FROM EXCLUDED.description
+   OR posts.content IS DISTINCT FROM EXCLUDED.content
+   OR posts.author IS DISTINCT FROM EXCLUDED.author
+   OR posts.comments_url IS DISTINCT FROM EXCLUDED.comments_url
+   OR posts.categories IS DISTINCT FROM EXCLUDED.categories
+RETURNING id, (xmax = 0) AS inserted
+`
+
+type UpsertPostParams struct {
+	Title       string
+	Url         string
+	Description sql.NullString
+	PublishedAt time.Time
+	FeedID      int32
+	Guid        string
+	Content     sql.NullString
+	Author      sql.NullString
+	CommentsUrl sql.NullString
+	Categories  []string
+}
+
+type UpsertPostRow struct {
+	ID       int32
+	Inserted bool
+}
+
+func (q *Queries) UpsertPost(ctx context.Context, arg UpsertPostParams) (UpsertPostRow, error) {
+	row := q.db.QueryRowContext(ctx, upsertPost,
+		arg.Title,
+		arg.Url,
+		arg.Description,
+		arg.PublishedAt,
+		arg.FeedID,
+		arg.Guid,
+		arg.Content,
+		arg.Author,
+		arg.CommentsUrl,
+		pq.Array(arg.Categories),
+	)
+	var i UpsertPostRow
+	err := row.Scan(&i.ID, &i.Inserted)
+	return i, err
+}
@@ -0,0 +1,201 @@
+// Package dateparse parses the dates found in RSS, RDF, Atom and JSON feeds,
+// which in practice stray a long way from the RFC 822 and RFC 3339 formats
+// the specs ask for.
+package dateparse
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zoneOffsets maps the zone abbreviations seen in feeds to their offsets
+// from UTC in minutes. time.Parse only knows the abbreviations of the local
+// zone and silently treats every other one as UTC, so they are rewritten to
+// numeric offsets before parsing.
+var zoneOffsets = map[string]int{
+	"UT": 0, "UTC": 0, "GMT": 0, "Z": 0, "WET": 0,
+	"EST": -5 * 60, "EDT": -4 * 60,
+	"CST": -6 * 60, "CDT": -5 * 60,
+	"MST": -7 * 60, "MDT": -6 * 60,
+	"PST": -8 * 60, "PDT": -7 * 60,
+	"AKST": -9 * 60, "AKDT": -8 * 60,
+	"HST": -10 * 60,
+	"AST": -4 * 60, "ADT": -3 * 60,
+	"NST": -3*60 - 30, "NDT": -2*60 - 30,
+	"BST": 1 * 60, "IST": 5*60 + 30, "WEST": 1 * 60,
+	"CET": 1 * 60, "CEST": 2 * 60, "MET": 1 * 60, "MEST": 2 * 60,
+	"EET": 2 * 60, "EEST": 3 * 60, "MSK": 3 * 60,
+	"SGT": 8 * 60, "HKT": 8 * 60, "AWST": 8 * 60,
+	"JST": 9 * 60, "KST": 9 * 60,
+	"ACST": 9*60 + 30, "ACDT": 10*60 + 30,
+	"AEST": 10 * 60, "AEDT": 11 * 60,
+	"NZST": 12 * 60, "NZDT": 13 * 60,
+}
+
+// isoLayouts cover RFC 3339 and the ISO 8601 shapes W3C-DTF and JSON Feed
+// publishers produce. Layouts without a zone are read as UTC.
+var isoLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.999999999Z0700",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z0700",
+	"2006-01-02 15:04:05.999999999 -0700",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02 15:04",
+	"20060102T150405Z0700",
+	"2006-01-02",
+	"2006-01",
+}
+
+// rfc822Layouts are tried once the weekday has been dropped and any zone
+// name rewritten as a numeric offset. Day and hour accept one or two
+// digits; seconds and the zone are optional.
+var rfc822Layouts = []string{
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04 -0700",
+	"2 Jan 06 15:04:05 -0700",
+	"2 Jan 06 15:04 -0700",
+	"2 January 2006 15:04:05 -0700",
+	"2 January 2006 15:04 -0700",
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 January 2006 15:04:05",
+	"2 January 2006 15:04",
+	"2 Jan 2006",
+	"2 January 2006",
+	"2-Jan-2006 15:04:05 -0700",
+	"2-Jan-2006 15:04:05",
+	// RFC 850, as in "Monday, 02-Jan-06 15:04:05 GMT".
+	"2-Jan-06 15:04:05 -0700",
+	"2-Jan-06 15:04:05",
+	// US ordering, as in "January 2, 2006 3:04 PM".
+	"Jan 2 2006 15:04:05 -0700",
+	"Jan 2 2006 3:04:05 PM -0700",
+	"Jan 2 2006 3:04 PM -0700",
+	"January 2 2006 15:04:05 -0700",
+	"January 2 2006 3:04:05 PM -0700",
+	"January 2 2006 3:04 PM -0700",
+	"Jan 2 2006 15:04:05",
+	"Jan 2 2006 3:04 PM",
+	"January 2 2006 15:04:05",
+	"January 2 2006 3:04 PM",
+	"Jan 2 2006",
+	"January 2 2006",
+	// ANSI C asctime, as in "Mon Jan  2 15:04:05 2006".
+	"Jan 2 15:04:05 2006",
+	"Jan 2 15:04:05 -0700 2006",
+}
+
+var (
+	comment     = regexp.MustCompile(`\s*\([^)]*\)\s*$`)
+	zoneOffset  = regexp.MustCompile(`^(?:GMT|UTC|UT)?([+-])(\d{1,2})(?::?(\d{2}))?$`)
+	weekdayName = regexp.MustCompile(`^(?i)(mon|tue|wed|thu|fri|sat|sun)[a-z]*\.?$`)
+)
+
+// Parse parses a feed date. Dates without a zone are taken to be UTC, and
+// the result is always in UTC, so equal instants store as equal values.
+func Parse(value string) (time.Time, error) {
+	s := strings.TrimSpace(value)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("dateparse: empty date")
+	}
+	for _, layout := range isoLayouts {
+		if t, err := time.Parse(layout, strings.ToUpper(s)); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	if t, ok := parseUnix(s); ok {
+		return t, nil
+	}
+	normalized := normalize(s)
+	for _, layout := range rfc822Layouts {
+		if t, err := time.Parse(layout, normalized); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("dateparse: unrecognized date %q", value)
+}
+
+// normalize reduces an RFC 822-like date to the shape rfc822Layouts expect:
+// single spaces, no weekday, commas or trailing comment, "Sep" for "Sept",
+// and a numeric zone offset in place of a zone name.
+func normalize(s string) string {
+	s = comment.ReplaceAllString(s, "")
+	fields := strings.Fields(strings.ReplaceAll(s, ",", " "))
+	if len(fields) > 0 && weekdayName.MatchString(fields[0]) {
+		fields = fields[1:]
+	}
+	for i, field := range fields {
+		// Months abbreviated with a period, as in "Jan." or "Sept.".
+		field = strings.TrimSuffix(field, ".")
+		if strings.EqualFold(field, "Sept") {
+			field = "Sep"
+		}
+		if upper := strings.ToUpper(field); upper == "AM" || upper == "PM" {
+			field = upper
+		}
+		fields[i] = field
+	}
+	// The zone is normally last, but asctime puts the year after it.
+	for i := len(fields) - 1; i >= 0 && i >= len(fields)-2; i-- {
+		if offset, ok := parseZone(fields[i]); ok {
+			fields[i] = offset
+			break
+		}
+	}
+	return strings.Join(fields, " ")
+}
+
+// parseZone converts a zone name or offset such as "EST", "GMT+2",
+// "+05:30" or "-0800" into the "-0700" form.
+func parseZone(field string) (string, bool) {
+	upper := strings.ToUpper(field)
+	if minutes, ok := zoneOffsets[upper]; ok {
+		return formatOffset(minutes), true
+	}
+	m := zoneOffset.FindStringSubmatch(upper)
+	if m == nil {
+		return "", false
+	}
+	hours, _ := strconv.Atoi(m[2])
+	minutes := 0
+	if m[3] != "" {
+		minutes, _ = strconv.Atoi(m[3])
+	}
+	if hours > 14 || minutes > 59 {
+		return "", false
+	}
+	total := hours*60 + minutes
+	if m[1] == "-" {
+		total = -total
+	}
+	return formatOffset(total), true
+}
+
+func formatOffset(minutes int) string {
+	sign := '+'
+	if minutes < 0 {
+		sign = '-'
+		minutes = -minutes
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, minutes/60, minutes%60)
+}
+
+// parseUnix accepts bare Unix timestamps in seconds, which a few JSON
+// generators emit.
+func parseUnix(s string) (time.Time, bool) {
+	if len(s) < 9 || len(s) > 10 {
+		return time.Time{}, false
+	}
+	secs, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(secs, 0).UTC(), true
+}
@@ -0,0 +1,131 @@
+package dateparse
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min, sec int) time.Time {
+		return time.Date(year, month, day, hour, min, sec, 0, time.UTC)
+	}
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		// RFC 822 and RFC 1123 as the specs ask for them.
+		{"Mon, 02 Jan 2006 15:04:05 GMT", utc(2006, 1, 2, 15, 4, 5)},
+		{"Mon, 02 Jan 2006 15:04:05 +0000", utc(2006, 1, 2, 15, 4, 5)},
+		{"Mon, 02 Jan 06 15:04:05 -0700", utc(2006, 1, 2, 22, 4, 5)},
+		// Single-digit days and hours.
+		{"Wed, 5 Jun 2024 9:00:00 GMT", utc(2024, 6, 5, 9, 0, 0)},
+		{"5 Jun 2024 10:00 +0200", utc(2024, 6, 5, 8, 0, 0)},
+		// Named zones that time.Parse would read as UTC.
+		{"Wed, 05 Jun 2024 10:00:00 EST", utc(2024, 6, 5, 15, 0, 0)},
+		{"Wed, 05 Jun 2024 10:00:00 PDT", utc(2024, 6, 5, 17, 0, 0)},
+		{"Wed, 05 Jun 2024 10:00:00 CEST", utc(2024, 6, 5, 8, 0, 0)},
+		{"Wed, 05 Jun 2024 10:00:00 ist", utc(2024, 6, 5, 4, 30, 0)},
+		{"Wed, 05 Jun 2024 10:00:00 GMT+2", utc(2024, 6, 5, 8, 0, 0)},
+		{"Wed, 05 Jun 2024 10:00:00 +05:30", utc(2024, 6, 5, 4, 30, 0)},
+		// Missing, long or misspelled weekdays.
+		{"05 Jun 2024 10:00:00 GMT", utc(2024, 6, 5, 10, 0, 0)},
+		{"Wednesday, 05 Jun 2024 10:00:00 GMT", utc(2024, 6, 5, 10, 0, 0)},
+		{"Thurs, 06 Jun 2024 10:00:00 GMT", utc(2024, 6, 6, 10, 0, 0)},
+		// Full and abbreviated months, with stray periods and commas.
+		{"Tue, 03 Sept. 2024 10:00:00 GMT", utc(2024, 9, 3, 10, 0, 0)},
+		{"5 June 2024 10:00:00 GMT", utc(2024, 6, 5, 10, 0, 0)},
+		{"June 5, 2024 3:04 PM", utc(2024, 6, 5, 15, 4, 0)},
+		{"Jun 5, 2024", utc(2024, 6, 5, 0, 0, 0)},
+		// No seconds or no zone.
+		{"Wed, 05 Jun 2024 10:00 GMT", utc(2024, 6, 5, 10, 0, 0)},
+		{"Wed, 05 Jun 2024 10:00:00", utc(2024, 6, 5, 10, 0, 0)},
+		// Trailing comments.
+		{"Wed, 05 Jun 2024 10:00:00 +0000 (UTC)", utc(2024, 6, 5, 10, 0, 0)},
+		{"Wed, 05 Jun 2024 10:00:00 -0400 (EDT)", utc(2024, 6, 5, 14, 0, 0)},
+		// RFC 850 and asctime.
+		{"Wednesday, 05-Jun-24 10:00:00 GMT", utc(2024, 6, 5, 10, 0, 0)},
+		{"05-Jun-2024 10:00:00 GMT", utc(2024, 6, 5, 10, 0, 0)},
+		{"Wed Jun  5 10:00:00 2024", utc(2024, 6, 5, 10, 0, 0)},
+		{"Wed Jun 5 10:00:00 PDT 2024", utc(2024, 6, 5, 17, 0, 0)},
+		// RFC 3339 and ISO 8601.
+		{"2024-06-05T10:00:00Z", utc(2024, 6, 5, 10, 0, 0)},
+		{"2024-06-05T10:00:00.123Z", time.Date(2024, 6, 5, 10, 0, 0, 123e6, time.UTC)},
+		{"2024-06-05T10:00:00+02:00", utc(2024, 6, 5, 8, 0, 0)},
+		{"2024-06-05T10:00:00+0200", utc(2024, 6, 5, 8, 0, 0)},
+		{"2024-06-05t10:00:00z", utc(2024, 6, 5, 10, 0, 0)},
+		{"2024-06-05T10:00+02:00", utc(2024, 6, 5, 8, 0, 0)},
+		{"2024-06-05T10:00:00", utc(2024, 6, 5, 10, 0, 0)},
+		{"2024-06-05 10:00:00", utc(2024, 6, 5, 10, 0, 0)},
+		{"2024-06-05 10:00:00 -0500", utc(2024, 6, 5, 15, 0, 0)},
+		{"20240605T100000Z", utc(2024, 6, 5, 10, 0, 0)},
+		{"2024-06-05", utc(2024, 6, 5, 0, 0, 0)},
+		{"2024-06", utc(2024, 6, 1, 0, 0, 0)},
+		// Surrounding whitespace and Unix timestamps.
+		{"  2024-06-05T10:00:00Z\n", utc(2024, 6, 5, 10, 0, 0)},
+		{"1717581600", utc(2024, 6, 5, 10, 0, 0)},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.in)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.in, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseSameInstant(t *testing.T) {
+	for _, pair := range [][2]string{
+		{"Wed, 05 Jun 2024 10:00:00 +0200", "Wed, 05 Jun 2024 08:00:00 GMT"},
+		{"2024-06-05T05:00:00-05:00", "2024-06-05T10:00:00Z"},
+		{"Wed, 05 Jun 2024 10:00:00 PDT", "2024-06-05T19:00:00+02:00"},
+	} {
+		a, errA := Parse(pair[0])
+		b, errB := Parse(pair[1])
+		if errA != nil || errB != nil {
+			t.Fatalf("Parse(%q, %q): %v, %v", pair[0], pair[1], errA, errB)
+		}
+		if a != b || a.Format(time.DateTime) != b.Format(time.DateTime) {
+			t.Errorf("Parse(%q) = %s and Parse(%q) = %s, want the same value", pair[0], a, pair[1], b)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, in := range []string{
+		"",
+		"   ",
+		"yesterday",
+		"Wed, 05 Foo 2024 10:00:00 GMT",
+		"Wed, 32 Jun 2024 10:00:00 GMT",
+		"Wed, 05 Jun 2024 25:00:00 GMT",
+		"Wed, 05 Jun 2024 10:00:00 +2500",
+		"12345",
+	} {
+		if got, err := Parse(in); err == nil {
+			t.Errorf("Parse(%q) = %s, want an error", in, got)
+		}
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, seed := range []string{
+		"Mon, 02 Jan 2006 15:04:05 GMT",
+		"Wednesday, 05-Jun-24 10:00:00 GMT",
+		"Wed Jun  5 10:00:00 2024",
+		"Wed, 05 Jun 2024 10:00:00 +0000 (UTC)",
+		"2024-06-05T10:00:00.123+02:00",
+		"June 5, 2024 3:04 PM",
+		"1717581600",
+	} {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, in string) {
+		got, err := Parse(in)
+		if err != nil && !got.IsZero() {
+			t.Errorf("Parse(%q) returned %s with error %v", in, got, err)
+		}
+	})
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// JSONFeed is a JSON Feed 1.0/1.1 document (https://jsonfeed.org).
+type JSONFeed struct {
+	Version     string           `json:"version"`
+	Title       string           `json:"title"`
+	HomePageURL string           `json:"home_page_url"`
+	FeedURL     string           `json:"feed_url"`
+	Description string           `json:"description"`
+	Authors     []JSONFeedAuthor `json:"authors"`
+	Author      *JSONFeedAuthor  `json:"author"`
+	Items       []JSONFeedItem   `json:"items"`
+}
+
+type JSONFeedItem struct {
+	ID            jsonFeedID           `json:"id"`
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Title         string               `json:"title"`
+	ContentHTML   string               `json:"content_html"`
+	ContentText   string               `json:"content_text"`
+	Summary       string               `json:"summary"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Authors       []JSONFeedAuthor     `json:"authors"`
+	Author        *JSONFeedAuthor      `json:"author"`
+	Tags          []string             `json:"tags"`
+	Attachments   []JSONFeedAttachment `json:"attachments"`
+}
+
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+type JSONFeedAttachment struct {
+	URL               string  `json:"url"`
+	MimeType          string  `json:"mime_type"`
+	Title             string  `json:"title"`
+	SizeInBytes       int64   `json:"size_in_bytes"`
+	DurationInSeconds float64 `json:"duration_in_seconds"`
+}
+
+// jsonFeedID accepts item ids published as numbers as well as the strings
+// the spec asks for.
+type jsonFeedID string
+
+func (id *jsonFeedID) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*id = jsonFeedID(s)
+		return nil
+	}
+	var n json.Number
+	if err := json.Unmarshal(data, &n); err != nil {
+		return err
+	}
+	*id = jsonFeedID(n.String())
+	return nil
+}
+
+// isJSONFeed reports whether a response is a JSON Feed, going by its
+// content type or, for servers that label it text/plain or similar, by
+// whether the body looks like a JSON object.
+func isJSONFeed(contentType string, body []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case "application/feed+json", "application/json":
+			return true
+		}
+	}
+	return bytes.HasPrefix(bytes.TrimSpace(body), []byte("{"))
+}
+
+// jsonFeedVersionPrefix starts the version URL of every JSON Feed.
+const jsonFeedVersionPrefix = "https://jsonfeed.org/version/"
+
+// parseJSONFeed decodes a JSON Feed and normalizes it into an RSSFeed. Other
+// JSON, such as an API's error body, is rejected by its missing version.
+func parseJSONFeed(body []byte) (*RSSFeed, error) {
+	var jf JSONFeed
+	if err := json.Unmarshal(body, &jf); err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(jf.Version, jsonFeedVersionPrefix) {
+		return nil, fmt.Errorf("not a JSON Feed: version %q", jf.Version)
+	}
+	feed := &RSSFeed{}
+	feed.Channel.Title = jf.Title
+	feed.Channel.Link = jf.HomePageURL
+	feed.Channel.Description = jf.Description
+	feedAuthors := jsonFeedAuthors(jf.Authors, jf.Author)
+	for _, ji := range jf.Items {
+		item := RSSItem{
+			Title:       ji.Title,
+			Link:        ji.URL,
+			Description: ji.Summary,
+			PubDate:     ji.DatePublished,
+			GUID:        string(ji.ID),
+			Author:      jsonFeedAuthors(ji.Authors, ji.Author),
+			Content:     ji.ContentHTML,
+			Categories:  uniqueCategories(ji.Tags),
+		}
+		if item.Link == "" {
+			item.Link = ji.ExternalURL
+		}
+		if item.Content == "" {
+			item.Content = ji.ContentText
+		}
+		if item.Description == "" {
+			item.Description = item.Content
+		}
+		if item.PubDate == "" {
+			item.PubDate = ji.DateModified
+		}
+		if item.Author == "" {
+			item.Author = feedAuthors
+		}
+		for _, attachment := range ji.Attachments {
+			enclosure := RSSEnclosure{
+				URL:  attachment.URL,
+				Type: attachment.MimeType,
+			}
+			if attachment.SizeInBytes > 0 {
+				enclosure.Length = strconv.FormatInt(attachment.SizeInBytes, 10)
+			}
+			if attachment.DurationInSeconds > 0 {
+				enclosure.Duration = strconv.FormatFloat(attachment.DurationInSeconds, 'f', -1, 64)
+			}
+			item.Enclosures = append(item.Enclosures, enclosure)
+		}
+		feed.Channel.Item = append(feed.Channel.Item, item)
+	}
+	return feed, nil
+}
+
+// jsonFeedAuthors joins the 1.1 authors array, falling back to the
+// deprecated 1.0 author object.
+func jsonFeedAuthors(authors []JSONFeedAuthor, author *JSONFeedAuthor) string {
+	if len(authors) == 0 && author != nil {
+		authors = []JSONFeedAuthor{*author}
+	}
+	var names []string
+	for _, a := range authors {
+		if name := strings.TrimSpace(a.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+const jsonFeedFixture = `{
+  "version": "https://jsonfeed.org/version/1.1",
+  "title": "Example & Co",
+  "home_page_url": "https://example.com/",
+  "description": "Notes",
+  "authors": [{"name": "Feed Author"}],
+  "items": [
+    {
+      "id": 42,
+      "url": "https://example.com/42",
+      "title": "Numbered",
+      "content_html": "<p>Hello</p>",
+      "summary": "Hi",
+      "date_published": "2024-06-05T10:00:00Z",
+      "author": {"name": "Old Style"},
+      "tags": ["go", "Go", " rss "],
+      "attachments": [
+        {"url": "https://example.com/42.mp3", "mime_type": "audio/mpeg", "size_in_bytes": 1024, "duration_in_seconds": 61.5}
+      ]
+    },
+    {
+      "id": "b",
+      "external_url": "https://elsewhere.example/b",
+      "content_text": "Plain body",
+      "date_modified": "2024-06-04T10:00:00Z"
+    }
+  ]
+}`
+
+func TestParseJSONFeed(t *testing.T) {
+	feed, err := parseFeed("application/feed+json", []byte(jsonFeedFixture))
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	if got := feed.Channel.Title; got != "Example & Co" {
+		t.Errorf("feed title %q", got)
+	}
+	if got := feed.Channel.Link; got != "https://example.com/" {
+		t.Errorf("feed link %q", got)
+	}
+	if len(feed.Channel.Item) != 2 {
+		t.Fatalf("got %d items, want 2", len(feed.Channel.Item))
+	}
+	first, second := feed.Channel.Item[0], feed.Channel.Item[1]
+	tests := []struct {
+		field, got, want string
+	}{
+		{"guid", first.GUID, "42"},
+		{"link", first.Link, "https://example.com/42"},
+		{"content", first.Content, "<p>Hello</p>"},
+		{"description", first.Description, "Hi"},
+		{"pubDate", first.PubDate, "2024-06-05T10:00:00Z"},
+		{"author", first.Author, "Old Style"},
+		{"second guid", second.GUID, "b"},
+		{"second link", second.Link, "https://elsewhere.example/b"},
+		{"second content", second.Content, "Plain body"},
+		{"second description", second.Description, "Plain body"},
+		{"second pubDate", second.PubDate, "2024-06-04T10:00:00Z"},
+		{"second author", second.Author, "Feed Author"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.field, tt.got, tt.want)
+		}
+	}
+	if !slices.Equal(first.Categories, []string{"go", "rss"}) {
+		t.Errorf("categories %q, want [go rss]", first.Categories)
+	}
+	want := RSSEnclosure{URL: "https://example.com/42.mp3", Type: "audio/mpeg", Length: "1024", Duration: "61.5"}
+	if len(first.Enclosures) != 1 || first.Enclosures[0] != want {
+		t.Errorf("enclosures %+v, want [%+v]", first.Enclosures, want)
+	}
+}
+
+func TestParseJSONFeedRejectsOtherJSON(t *testing.T) {
+	tests := []struct {
+		contentType, body string
+	}{
+		{"application/json", `{"message":"Not Found"}`},
+		{"application/json; charset=utf-8", `{"version":"1.1","items":[]}`},
+		{"text/plain", `{"version":"https://example.com/version/1","items":[]}`},
+		{"application/feed+json", `{"version":`},
+		{"application/feed+json", `[]`},
+	}
+	for _, tt := range tests {
+		if _, err := parseFeed(tt.contentType, []byte(tt.body)); err == nil {
+			t.Errorf("parseFeed(%q, %q) succeeded, want an error", tt.contentType, tt.body)
+		}
+	}
+}
+
+func TestIsJSONFeed(t *testing.T) {
+	tests := []struct {
+		contentType, body string
+		want              bool
+	}{
+		{"application/feed+json", `{}`, true},
+		{"application/json; charset=utf-8", `{}`, true},
+		{"text/plain", "  \n{\"version\":\"\"}", true},
+		{"application/rss+xml", `<rss/>`, false},
+		{"", `<?xml version="1.0"?><feed/>`, false},
+	}
+	for _, tt := range tests {
+		if got := isJSONFeed(tt.contentType, []byte(tt.body)); got != tt.want {
+			t.Errorf("isJSONFeed(%q, %q) = %v, want %v", tt.contentType, tt.body, got, tt.want)
+		}
+	}
+}
@@ -55,13 +55,32 @@ func main() {
 	cmds.register("users", handlerGetUsers)
 	cmds.register("agg", handlerFetchFeed)
 	cmds.register("addfeed", middlewareLoggedIn(handlerAddFeed))
+	cmds.register("setinterval", middlewareLoggedIn(handlerSetInterval))
 	cmds.register("feeds", handlerFeeds)
+	cmds.register("enablefeed", middlewareLoggedIn(handlerEnableFeed))
 	cmds.register("follow", middlewareLoggedIn(handlerFollow))
 	cmds.register("following", middlewareLoggedIn(handlerFollowing))
 	cmds.register("unfollow", middlewareLoggedIn(handlerUnfollow))
 	cmds.register("help", handlerHelp)
 	cmds.register("scrapefeeds", handlerScrapeFeeds)
-	cmds.register("browse", handlerBrowse)
+	cmds.register("prune", handlerPrune)
+	cmds.register("retention", middlewareLoggedIn(handlerRetention))
+	cmds.register("browse", middlewareLoggedIn(handlerBrowse))
+	cmds.register("read", middlewareLoggedIn(handlerRead))
+	cmds.register("mark-read", middlewareLoggedIn(handlerMarkRead))
+	cmds.register("star", middlewareLoggedIn(handlerStar))
+	cmds.register("unstar", middlewareLoggedIn(handlerUnstar))
+	cmds.register("starred", middlewareLoggedIn(handlerStarred))
+	cmds.register("search", middlewareLoggedIn(handlerSearch))
+	cmds.register("tag", middlewareLoggedIn(handlerTag))
+	cmds.register("untag", middlewareLoggedIn(handlerUntag))
+	cmds.register("folders", middlewareLoggedIn(handlerFolders))
+	cmds.register("download", handlerDownload)
+	cmds.register("import", middlewareLoggedIn(handlerImport))
+	cmds.register("export", middlewareLoggedIn(handlerExport))
+	cmds.register("serve", handlerServe)
+	cmds.register("token", middlewareLoggedIn(handlerToken))
+	cmds.register("fever", middlewareLoggedIn(handlerFever))
 
 	// Initialize application state
 	appState := &state{
@@ -99,15 +118,34 @@ func main() {
 		fmt.Println("  register <username> - Register a new user")
 		fmt.Println("  reset - Reset the database")
 		fmt.Println("  users - Get all users")
-		fmt.Println("  agg - run aggregator service")
-		fmt.Println("  addfeed <name> <url> - Add a new feed with the specified name and URL")
-		fmt.Println("  feeds - List all feeds")
+		fmt.Println("  agg <interval> [--workers n] [--per-host n] [--prune interval] - run aggregator service")
+		fmt.Println("  addfeed <name> <url> [--interval <duration|adaptive>] - Add a new feed by its URL or its site's page")
+		fmt.Println("  setinterval <feed> <duration|adaptive|default> - Change how often a feed is fetched")
+		fmt.Println("  feeds [--health] - List all feeds, or their fetch health")
+		fmt.Println("  enablefeed <feed> - Re-enable a feed disabled after repeated failures")
 		fmt.Println("  follow <url> - Follow a feed by URL")
 		fmt.Println("  following - List all followed feeds")
 		fmt.Println("  unfollow <url> - Unfollow a feed by URL")
-		fmt.Println("  scrapefeeds - Scrape all feeds")
+		fmt.Println("  scrapefeeds - Scrape all due feeds once")
+		fmt.Println("  prune [feed] - Delete posts past their retention limits, keeping starred posts")
+		fmt.Println("  retention [feed] [--max-age age] [--max-posts n] - Show or set the default or a feed's retention limits")
 		fmt.Println("  help - Show this help message")
-		fmt.Println("  browse - Browse feeds in the database")
+		fmt.Println("  browse [limit] [feed] [--author name] [--category name] [--folder name] [--unread] - Browse posts from followed feeds")
+		fmt.Println("  read <post_id> - Show a post and mark it read")
+		fmt.Println("  mark-read <feed> [--before date] | --before date | --all - Mark posts as read")
+		fmt.Println("  star <post_id> - Star a post to keep it")
+		fmt.Println("  unstar <post_id> - Remove a post's star")
+		fmt.Println("  starred [limit] - List starred posts")
+		fmt.Println("  search <query> [--limit n] [--folder name] - Search posts in followed feeds")
+		fmt.Println("  tag <feed> <folder> - Add a followed feed to a folder")
+		fmt.Println("  untag <feed> <folder> - Remove a feed from a folder")
+		fmt.Println("  folders - List your folders")
+		fmt.Println("  download <post_id|feed> [dir] [--limit n] - Download enclosures, resuming partial downloads")
+		fmt.Println("  import <file.opml> - Import and follow the feeds in an OPML file")
+		fmt.Println("  export [file] - Export followed feeds as OPML")
+		fmt.Println("  serve [--addr host:port] - Serve the JSON, Fever and Google Reader APIs")
+		fmt.Println("  token create <name> | list | revoke <id> - Manage your API tokens")
+		fmt.Println("  fever enable | disable - Set (prompted, or read from stdin) or remove your password for Fever and Google Reader clients")
 		os.Exit(1)
 	}
 }
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Specter242/Gator/internal/database"
+)
+
+// OPML is an OPML 2.0 subscription list. Folders are outlines without an
+// xmlUrl whose children are feeds or further folders.
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    struct {
+		Title       string `xml:"title,omitempty"`
+		DateCreated string `xml:"dateCreated,omitempty"`
+		OwnerName   string `xml:"ownerName,omitempty"`
+	} `xml:"head"`
+	Body struct {
+		Outlines []OPMLOutline `xml:"outline"`
+	} `xml:"body"`
+}
+
+type OPMLOutline struct {
+	Text     string        `xml:"text,attr"`
+	Title    string        `xml:"title,attr,omitempty"`
+	Type     string        `xml:"type,attr,omitempty"`
+	XMLURL   string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL  string        `xml:"htmlUrl,attr,omitempty"`
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+// opmlFeed is a feed listed in an OPML file together with every folder it
+// appears in. Nested folders are joined with "/", as in "Tech/Go".
+type opmlFeed struct {
+	Title   string
+	URL     string
+	Folders []string
+}
+
+// parseOPML reads an OPML document and lists its feeds in document order.
+// A feed listed under several folders is returned once, in all of them.
+func parseOPML(r io.Reader) ([]opmlFeed, error) {
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var doc OPML
+	if err := newXMLDecoder(body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	var feeds []opmlFeed
+	index := make(map[string]int)
+	var walk func(outlines []OPMLOutline, folder string)
+	walk = func(outlines []OPMLOutline, folder string) {
+		for _, o := range outlines {
+			title := strings.TrimSpace(o.Title)
+			if title == "" {
+				title = strings.TrimSpace(o.Text)
+			}
+			feedURL := strings.TrimSpace(o.XMLURL)
+			if feedURL == "" {
+				sub := folder
+				if title != "" {
+					sub = strings.Trim(folder+"/"+title, "/")
+				}
+				walk(o.Outlines, sub)
+				continue
+			}
+			i, ok := index[feedURL]
+			if !ok {
+				i = len(feeds)
+				index[feedURL] = i
+				feeds = append(feeds, opmlFeed{Title: title, URL: feedURL})
+			}
+			if folder != "" && !containsFold(feeds[i].Folders, folder) {
+				feeds[i].Folders = append(feeds[i].Folders, folder)
+			}
+		}
+	}
+	walk(doc.Body.Outlines, "")
+	return feeds, nil
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateFeeds fetches each URL, a few at a time, and returns the error
+// for every one that isn't a working feed.
+func validateFeeds(ctx context.Context, urls []string) map[string]error {
+	failures := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, defaultAggWorkers*2)
+	for _, u := range urls {
+		wg.Add(1)
+		go func(feedURL string) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if _, err := fetchFeed(ctx, feedURL); err != nil {
+				mu.Lock()
+				failures[feedURL] = err
+				mu.Unlock()
+			}
+		}(u)
+	}
+	wg.Wait()
+	return failures
+}
+
+// opmlFolder is a node of the folder tree built for export.
+type opmlFolder struct {
+	feeds      []OPMLOutline
+	subfolders map[string]*opmlFolder
+}
+
+func newOPMLFolder() *opmlFolder {
+	return &opmlFolder{subfolders: make(map[string]*opmlFolder)}
+}
+
+func (f *opmlFolder) outlines() []OPMLOutline {
+	names := make([]string, 0, len(f.subfolders))
+	for name := range f.subfolders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var outlines []OPMLOutline
+	for _, name := range names {
+		outlines = append(outlines, OPMLOutline{
+			Text:     name,
+			Title:    name,
+			Outlines: f.subfolders[name].outlines(),
+		})
+	}
+	return append(outlines, f.feeds...)
+}
+
+// buildOPML writes feeds into an OPML document, nesting each under the
+// folders it belongs to; a feed in several folders is listed in each.
+func buildOPML(owner string, feeds []opmlFeed) OPML {
+	root := newOPMLFolder()
+	for _, feed := range feeds {
+		outline := OPMLOutline{
+			Text:   feed.Title,
+			Title:  feed.Title,
+			Type:   "rss",
+			XMLURL: feed.URL,
+		}
+		if len(feed.Folders) == 0 {
+			root.feeds = append(root.feeds, outline)
+			continue
+		}
+		for _, folder := range feed.Folders {
+			node := root
+			for _, name := range strings.Split(folder, "/") {
+				child, ok := node.subfolders[name]
+				if !ok {
+					child = newOPMLFolder()
+					node.subfolders[name] = child
+				}
+				node = child
+			}
+			node.feeds = append(node.feeds, outline)
+		}
+	}
+	doc := OPML{Version: "2.0"}
+	doc.Head.Title = "Gator subscriptions"
+	doc.Head.DateCreated = time.Now().UTC().Format(time.RFC1123Z)
+	doc.Head.OwnerName = owner
+	doc.Body.Outlines = root.outlines()
+	return doc
+}
+
+// writeOPML encodes doc with an XML declaration and indentation.
+func writeOPML(w io.Writer, doc OPML) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// importOPMLFeed adds feed to Gator, reusing the existing row for its URL
+// when there is one, follows it for user and files the follow under the
+// feed's folders. It reports whether a feed row was created and whether the
+// user wasn't already following it.
+func importOPMLFeed(ctx context.Context, s *state, user database.User, feed opmlFeed, existing *database.Feed) (bool, bool, error) {
+	var feedID int32
+	created := existing == nil
+	if existing != nil {
+		feedID = existing.ID
+	} else {
+		name, err := uniqueFeedName(ctx, s, feed)
+		if err != nil {
+			return false, false, err
+		}
+		row, err := s.db.CreateFeed(ctx, database.CreateFeedParams{
+			Name:   name,
+			Url:    feed.URL,
+			UserID: user.ID,
+		})
+		if err != nil {
+			return false, false, fmt.Errorf("error creating feed: %v", err)
+		}
+		feedID = row.ID
+	}
+	follow, err := s.db.FollowFeed(ctx, database.FollowFeedParams{
+		UserID: user.ID,
+		FeedID: feedID,
+	})
+	if err != nil {
+		return created, false, fmt.Errorf("error following feed: %v", err)
+	}
+	for _, folder := range feed.Folders {
+		folder, err := cleanFolderName(folder)
+		if err != nil {
+			return created, follow.Inserted, err
+		}
+		folderID, err := s.db.UpsertFolder(ctx, database.UpsertFolderParams{
+			UserID: user.ID,
+			Name:   folder,
+		})
+		if err != nil {
+			return created, follow.Inserted, fmt.Errorf("error creating folder %s: %v", folder, err)
+		}
+		err = s.db.AddFeedFollowToFolder(ctx, database.AddFeedFollowToFolderParams{
+			FeedFollowID: follow.ID,
+			FolderID:     folderID,
+		})
+		if err != nil {
+			return created, follow.Inserted, fmt.Errorf("error adding feed to folder %s: %v", folder, err)
+		}
+	}
+	return created, follow.Inserted, nil
+}
+
+// uniqueFeedName picks an unused feed name for an imported feed, since
+// feed names are unique across all users: the OPML title, falling back to
+// the feed's host, with a number appended if it is taken.
+func uniqueFeedName(ctx context.Context, s *state, feed opmlFeed) (string, error) {
+	base := feed.Title
+	if base == "" {
+		base = feedHost(feed.URL)
+	}
+	name := base
+	for n := 2; ; n++ {
+		_, err := s.db.GetFeedByNameOrURL(ctx, name)
+		if errors.Is(err, sql.ErrNoRows) {
+			return name, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("error checking feed name: %v", err)
+		}
+		name = fmt.Sprintf("%s (%d)", base, n)
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseOPML(t *testing.T) {
+	tests := []struct {
+		name, doc string
+		want      []opmlFeed
+	}{
+		{
+			"flat",
+			`<opml version="1.0"><body>
+			<outline text="One" type="rss" xmlUrl="https://one.example/feed"/>
+			<outline title="Two" text="ignored" xmlUrl=" https://two.example/rss "/>
+			<outline xmlUrl="https://three.example/atom"/>
+			</body></opml>`,
+			[]opmlFeed{
+				{Title: "One", URL: "https://one.example/feed"},
+				{Title: "Two", URL: "https://two.example/rss"},
+				{URL: "https://three.example/atom"},
+			},
+		},
+		{
+			"nested folders and repeats",
+			`<?xml version="1.0" encoding="UTF-8"?>
+			<opml version="2.0"><head><title>Subs</title></head><body>
+			<outline text="Tech">
+				<outline text="Go" xmlUrl="https://go.dev/blog/feed.atom"/>
+				<outline text="Languages">
+					<outline text="Go blog" xmlUrl="https://go.dev/blog/feed.atom"/>
+				</outline>
+			</outline>
+			<outline text="tech"><outline text="Go" xmlUrl="https://go.dev/blog/feed.atom"/></outline>
+			<outline text=""><outline text="Loose" xmlUrl="https://loose.example/rss"/></outline>
+			</body></opml>`,
+			[]opmlFeed{
+				{Title: "Go", URL: "https://go.dev/blog/feed.atom", Folders: []string{"Tech", "Tech/Languages"}},
+				{Title: "Loose", URL: "https://loose.example/rss"},
+			},
+		},
+		{
+			"latin-1",
+			"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><opml><body><outline text=\"Caf\xe9\" xmlUrl=\"https://cafe.example/rss\"/></body></opml>",
+			[]opmlFeed{{Title: "Café", URL: "https://cafe.example/rss"}},
+		},
+		{
+			"no feeds",
+			`<opml version="2.0"><head/><body><outline text="Empty"/></body></opml>`,
+			nil,
+		},
+	}
+	for _, tt := range tests {
+		got, err := parseOPML(strings.NewReader(tt.doc))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseOPMLInvalid(t *testing.T) {
+	for _, doc := range []string{
+		``,
+		`<opml><body><outline text="x" xmlUrl="https://x.example/rss">`,
+		`<rss version="2.0"><channel/></rss>`,
+	} {
+		if feeds, err := parseOPML(strings.NewReader(doc)); err == nil {
+			t.Errorf("parseOPML(%q) = %+v, want an error", doc, feeds)
+		}
+	}
+}
+
+func TestOPMLRoundTrip(t *testing.T) {
+	feeds := []opmlFeed{
+		{Title: "Go & friends", URL: "https://go.dev/blog/feed.atom?a=1&b=2", Folders: []string{"Tech/Go", "Tech"}},
+		{Title: "News", URL: "https://news.example/rss"},
+		{Title: "Zig", URL: "https://zig.example/rss", Folders: []string{"Tech"}},
+	}
+	var buf bytes.Buffer
+	if err := writeOPML(&buf, buildOPML("jo", feeds)); err != nil {
+		t.Fatalf("writeOPML: %v", err)
+	}
+	if !strings.HasPrefix(buf.String(), `<?xml version="1.0" encoding="UTF-8"?>`) {
+		t.Errorf("missing XML declaration:\n%s", buf.String())
+	}
+	got, err := parseOPML(&buf)
+	if err != nil {
+		t.Fatalf("parseOPML: %v", err)
+	}
+	// Subfolders come before feeds at each level, so the order changes.
+	want := []opmlFeed{
+		{Title: "Go & friends", URL: "https://go.dev/blog/feed.atom?a=1&b=2", Folders: []string{"Tech/Go", "Tech"}},
+		{Title: "Zig", URL: "https://zig.example/rss", Folders: []string{"Tech"}},
+		{Title: "News", URL: "https://news.example/rss"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip got %+v, want %+v", got, want)
+	}
+}
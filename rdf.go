@@ -0,0 +1,42 @@
+package main
+
+import "strings"
+
+// RDFFeed is an RSS 1.0 <rdf:RDF> document. Unlike RSS 2.0, items are
+// siblings of the channel rather than its children.
+type RDFFeed struct {
+	Channel struct {
+		RSSChannel
+		DCDate string `xml:"http://purl.org/dc/elements/1.1/ date"`
+	} `xml:"channel"`
+	Items []RDFItem `xml:"item"`
+}
+
+type RDFItem struct {
+	RSSItem
+	About string `xml:"http://www.w3.org/1999/02/22-rdf-syntax-ns# about,attr"`
+}
+
+// parseRDF decodes an RSS 1.0 document and normalizes it into an RSSFeed.
+func parseRDF(body []byte) (*RSSFeed, error) {
+	var rdf RDFFeed
+	if err := newXMLDecoder(body).Decode(&rdf); err != nil {
+		return nil, err
+	}
+	rdf.Channel.normalize()
+	feed := &RSSFeed{}
+	feed.Channel.Title = strings.TrimSpace(rdf.Channel.Title)
+	feed.Channel.Link = strings.TrimSpace(rdf.Channel.Link)
+	feed.Channel.Description = strings.TrimSpace(rdf.Channel.Description)
+	feed.Channel.PubDate = rdf.Channel.DCDate
+	feed.Channel.RSSScheduleHints = rdf.Channel.RSSScheduleHints
+	for _, ri := range rdf.Items {
+		item := ri.RSSItem
+		item.normalize()
+		if item.GUID == "" {
+			item.GUID = ri.About
+		}
+		feed.Channel.Item = append(feed.Channel.Item, item)
+	}
+	return feed, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+const rdfFixture = `<?xml version="1.0" encoding="utf-8"?>
+<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"
+  xmlns="http://purl.org/rss/1.0/"
+  xmlns:dc="http://purl.org/dc/elements/1.1/"
+  xmlns:sy="http://purl.org/rss/1.0/modules/syndication/"
+  xmlns:content="http://purl.org/rss/1.0/modules/content/">
+  <channel rdf:about="https://example.com/">
+    <title> Example RDF </title>
+    <link>https://example.com/</link>
+    <description>Notes &amp;amp; more</description>
+    <dc:date>2024-06-05T10:00:00Z</dc:date>
+    <sy:updatePeriod>daily</sy:updatePeriod>
+    <sy:updateFrequency>2</sy:updateFrequency>
+  </channel>
+  <item rdf:about="https://example.com/1">
+    <title>First</title>
+    <link>https://example.com/1</link>
+    <description>Summary</description>
+    <content:encoded><![CDATA[<p>Full</p>]]></content:encoded>
+    <dc:date>2024-06-05T09:00:00Z</dc:date>
+    <dc:creator>Jo</dc:creator>
+    <dc:subject>news</dc:subject>
+    <dc:subject>News</dc:subject>
+  </item>
+  <item rdf:about="https://example.com/2">
+    <title>Second</title>
+    <link>https://example.com/2</link>
+  </item>
+</rdf:RDF>`
+
+func TestParseRDF(t *testing.T) {
+	feed, err := parseFeed("application/rdf+xml", []byte(rdfFixture))
+	if err != nil {
+		t.Fatalf("parseFeed: %v", err)
+	}
+	channel := feed.Channel
+	tests := []struct {
+		field, got, want string
+	}{
+		{"title", channel.Title, "Example RDF"},
+		{"link", channel.Link, "https://example.com/"},
+		{"description", channel.Description, "Notes & more"},
+		{"pubDate", channel.PubDate, "2024-06-05T10:00:00Z"},
+		{"updatePeriod", channel.UpdatePeriod, "daily"},
+		{"updateFrequency", channel.UpdateFrequency, "2"},
+	}
+	if len(channel.Item) != 2 {
+		t.Fatalf("got %d items, want 2", len(channel.Item))
+	}
+	first, second := channel.Item[0], channel.Item[1]
+	tests = append(tests, []struct {
+		field, got, want string
+	}{
+		{"item title", first.Title, "First"},
+		{"item link", first.Link, "https://example.com/1"},
+		{"item guid", first.GUID, "https://example.com/1"},
+		{"item description", first.Description, "Summary"},
+		{"item content", first.Content, "<p>Full</p>"},
+		{"item pubDate", first.PubDate, "2024-06-05T09:00:00Z"},
+		{"item author", first.Author, "Jo"},
+		{"second guid", second.GUID, "https://example.com/2"},
+		{"second pubDate", second.PubDate, ""},
+	}...)
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.field, tt.got, tt.want)
+		}
+	}
+	if !slices.Equal(first.Categories, []string{"news"}) {
+		t.Errorf("categories %q, want [news]", first.Categories)
+	}
+}
+
+func TestParseFeedUnsupported(t *testing.T) {
+	for _, body := range []string{
+		`<html><body>not a feed</body></html>`,
+		`<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#"><item>`,
+		``,
+	} {
+		if _, err := parseFeed("application/xml", []byte(body)); err == nil {
+			t.Errorf("parseFeed(%q) succeeded, want an error", body)
+		}
+	}
+}
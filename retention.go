@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Specter242/Gator/internal/config"
+	"github.com/Specter242/Gator/internal/database"
+)
+
+// pruneBatchSize is how many posts each delete removes. Every batch is its
+// own statement, so posts are only locked briefly while agg keeps writing.
+const pruneBatchSize = 500
+
+// retentionPolicy says which posts of a feed are kept. A zero MaxAge or
+// MaxPosts means no limit.
+type retentionPolicy struct {
+	MaxAge   time.Duration
+	MaxPosts int
+}
+
+func (p retentionPolicy) String() string {
+	var limits []string
+	if p.MaxAge > 0 {
+		limits = append(limits, "for "+formatRetentionAge(p.MaxAge))
+	}
+	if p.MaxPosts > 0 {
+		limits = append(limits, fmt.Sprintf("up to the newest %d", p.MaxPosts))
+	}
+	if len(limits) == 0 {
+		return "kept forever"
+	}
+	return "kept " + strings.Join(limits, " and ")
+}
+
+// retained reports which of a feed's items, given their publication times,
+// the policy keeps. Scrapes skip the others, so items still listed in the
+// feed don't come back as new posts after pruning removed them.
+func (p retentionPolicy) retained(published []time.Time, now time.Time) []bool {
+	keep := make([]bool, len(published))
+	cutoff := now.UTC().Add(-p.MaxAge)
+	for i, t := range published {
+		keep[i] = p.MaxAge == 0 || !t.UTC().Before(cutoff)
+	}
+	if p.MaxPosts > 0 && len(published) > p.MaxPosts {
+		newest := make([]int, len(published))
+		for i := range newest {
+			newest[i] = i
+		}
+		slices.SortStableFunc(newest, func(a, b int) int {
+			return published[b].Compare(published[a])
+		})
+		for _, i := range newest[p.MaxPosts:] {
+			keep[i] = false
+		}
+	}
+	return keep
+}
+
+// parseRetentionAge reads a maximum post age: a number of days such as
+// "90d", a Go duration such as "720h", or "off" for no limit.
+func parseRetentionAge(arg string) (time.Duration, error) {
+	arg = strings.TrimSpace(arg)
+	if arg == "off" || arg == "0" {
+		return 0, nil
+	}
+	var age time.Duration
+	if days, ok := strings.CutSuffix(arg, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q: use days like 90d, a duration like 720h, or off", arg)
+		}
+		age = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q: use days like 90d, a duration like 720h, or off", arg)
+		}
+		age = d
+	}
+	if age < time.Hour || age > math.MaxInt32*time.Second {
+		return 0, fmt.Errorf("invalid age %q: must be between 1h and 68 years", arg)
+	}
+	return age, nil
+}
+
+// parseRetentionPosts reads a maximum post count, or "off" for no limit.
+func parseRetentionPosts(arg string) (int, error) {
+	if arg == "off" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 0 || n > math.MaxInt32 {
+		return 0, fmt.Errorf("invalid post count %q: use a number or off", arg)
+	}
+	return n, nil
+}
+
+func formatRetentionAge(age time.Duration) string {
+	if age%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", age/(24*time.Hour))
+	}
+	return age.String()
+}
+
+// defaultRetention is the policy in the config file, used by every feed
+// that doesn't override it.
+func defaultRetention(cfg *config.Config) (retentionPolicy, error) {
+	var policy retentionPolicy
+	if cfg.RetentionMaxAge != "" {
+		age, err := parseRetentionAge(cfg.RetentionMaxAge)
+		if err != nil {
+			return policy, fmt.Errorf("retention_max_age in config: %v", err)
+		}
+		policy.MaxAge = age
+	}
+	if cfg.RetentionMaxPosts < 0 {
+		return policy, fmt.Errorf("retention_max_posts in config must not be negative")
+	}
+	policy.MaxPosts = cfg.RetentionMaxPosts
+	return policy, nil
+}
+
+// feedRetention applies a feed's overrides to the default policy. A NULL
+// column falls back to the default; zero turns the limit off for the feed.
+func feedRetention(def retentionPolicy, maxAgeSeconds, maxPosts sql.NullInt32) retentionPolicy {
+	policy := def
+	if maxAgeSeconds.Valid {
+		policy.MaxAge = time.Duration(maxAgeSeconds.Int32) * time.Second
+	}
+	if maxPosts.Valid {
+		policy.MaxPosts = int(maxPosts.Int32)
+	}
+	return policy
+}
+
+// pruneFeed deletes the feed's posts that its policy no longer keeps, in
+// batches, and returns how many were removed. Starred posts are never
+// deleted.
+func pruneFeed(ctx context.Context, s *state, feedID int32, policy retentionPolicy) (int64, error) {
+	var removed int64
+	if policy.MaxAge > 0 {
+		before := time.Now().UTC().Add(-policy.MaxAge)
+		for {
+			n, err := s.db.PruneExpiredPosts(ctx, database.PruneExpiredPostsParams{
+				FeedID:    feedID,
+				Before:    before,
+				BatchSize: pruneBatchSize,
+			})
+			removed += n
+			if err != nil {
+				return removed, fmt.Errorf("error deleting old posts: %v", err)
+			}
+			if n < pruneBatchSize {
+				break
+			}
+		}
+	}
+	if policy.MaxPosts > 0 {
+		for {
+			n, err := s.db.PruneExcessPosts(ctx, database.PruneExcessPostsParams{
+				FeedID:    feedID,
+				Keep:      int32(policy.MaxPosts),
+				BatchSize: pruneBatchSize,
+			})
+			removed += n
+			if err != nil {
+				return removed, fmt.Errorf("error deleting excess posts: %v", err)
+			}
+			if n < pruneBatchSize {
+				break
+			}
+		}
+	}
+	return removed, nil
+}
+
+// feedPruneResult is what pruning removed from one feed.
+type feedPruneResult struct {
+	Name    string
+	Removed int64
+	Err     error
+}
+
+// pruneFeeds applies each feed's retention policy and reports what it
+// removed per feed. A failing feed doesn't stop the others.
+func pruneFeeds(ctx context.Context, s *state, feeds []database.GetFeedRetentionsRow) ([]feedPruneResult, error) {
+	def, err := defaultRetention(s.Config)
+	if err != nil {
+		return nil, err
+	}
+	var results []feedPruneResult
+	for _, feed := range feeds {
+		policy := feedRetention(def, feed.RetentionMaxAgeSeconds, feed.RetentionMaxPosts)
+		if policy.MaxAge == 0 && policy.MaxPosts == 0 {
+			continue
+		}
+		removed, err := pruneFeed(ctx, s, feed.ID, policy)
+		results = append(results, feedPruneResult{Name: feed.Name, Removed: removed, Err: err})
+	}
+	return results, nil
+}
+
+// pruneAll prunes every feed and logs a one-line summary, for agg.
+func pruneAll(ctx context.Context, s *state) error {
+	feeds, err := s.db.GetFeedRetentions(ctx)
+	if err != nil {
+		return fmt.Errorf("error getting feeds: %v", err)
+	}
+	results, err := pruneFeeds(ctx, s, feeds)
+	if err != nil {
+		return err
+	}
+	var removed int64
+	for _, res := range results {
+		removed += res.Removed
+		if res.Err != nil {
+			log.Printf("Pruning %s failed: %v", res.Name, res.Err)
+		}
+	}
+	log.Printf("Pruned %d posts from %d feeds", removed, len(results))
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Specter242/Gator/internal/database"
+)
+
+const (
+	defaultFetchInterval = time.Hour
+	minAdaptiveInterval  = 15 * time.Minute
+	maxAdaptiveInterval  = 24 * time.Hour
+	adaptiveWindow       = 7 * 24 * time.Hour
+)
+
+// scheduleHints is what a feed document says about how often it should be
+// polled. MinInterval is a floor; skip hours and days are in GMT.
+type scheduleHints struct {
+	MinInterval time.Duration
+	SkipHours   map[int]bool
+	SkipDays    map[time.Weekday]bool
+}
+
+func hintsFromFeed(feed *RSSFeed) scheduleHints {
+	raw := feed.Channel.RSSScheduleHints
+	hints := scheduleHints{
+		SkipHours: make(map[int]bool),
+		SkipDays:  make(map[time.Weekday]bool),
+	}
+	if ttl, err := strconv.Atoi(strings.TrimSpace(raw.TTL)); err == nil && ttl > 0 {
+		hints.MinInterval = time.Duration(ttl) * time.Minute
+	}
+	if period := syndicationPeriod(raw.UpdatePeriod); period > 0 {
+		frequency, err := strconv.Atoi(strings.TrimSpace(raw.UpdateFrequency))
+		if err != nil || frequency <= 0 {
+			frequency = 1
+		}
+		if interval := period / time.Duration(frequency); interval > hints.MinInterval {
+			hints.MinInterval = interval
+		}
+	}
+	for _, h := range raw.SkipHours {
+		if hour, err := strconv.Atoi(strings.TrimSpace(h)); err == nil && hour >= 0 && hour < 24 {
+			hints.SkipHours[hour] = true
+		}
+	}
+	for _, d := range raw.SkipDays {
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			if strings.EqualFold(strings.TrimSpace(d), day.String()) {
+				hints.SkipDays[day] = true
+			}
+		}
+	}
+	return hints
+}
+
+// storedHints returns the hints saved with feed by its last full fetch, for
+// scheduling after a 304.
+func storedHints(feed database.Feed) scheduleHints {
+	hints := scheduleHints{
+		SkipHours: make(map[int]bool),
+		SkipDays:  make(map[time.Weekday]bool),
+	}
+	if feed.MinIntervalSeconds.Valid {
+		hints.MinInterval = time.Duration(feed.MinIntervalSeconds.Int32) * time.Second
+	}
+	for _, hour := range feed.SkipHours {
+		hints.SkipHours[int(hour)] = true
+	}
+	for _, day := range feed.SkipDays {
+		hints.SkipDays[time.Weekday(day)] = true
+	}
+	return hints
+}
+
+func syndicationPeriod(period string) time.Duration {
+	switch strings.ToLower(strings.TrimSpace(period)) {
+	case "hourly":
+		return time.Hour
+	case "daily":
+		return 24 * time.Hour
+	case "weekly":
+		return 7 * 24 * time.Hour
+	case "monthly":
+		return 30 * 24 * time.Hour
+	case "yearly":
+		return 365 * 24 * time.Hour
+	}
+	return 0
+}
+
+// nextFetchDelay returns how long from now to wait before polling again:
+// interval, raised to the publisher's minimum and extended past any skipped
+// hours or days.
+func nextFetchDelay(now time.Time, interval time.Duration, hints scheduleHints) time.Duration {
+	if hints.MinInterval > interval {
+		interval = hints.MinInterval
+	}
+	next := now.Add(interval).UTC()
+	// A week of hours covers every combination; a feed skipping all of them
+	// is simply polled at the end of the week.
+	for i := 0; i < 7*24; i++ {
+		if !hints.SkipHours[next.Hour()] && !hints.SkipDays[next.Weekday()] {
+			break
+		}
+		next = next.Truncate(time.Hour).Add(time.Hour)
+	}
+	return next.Sub(now)
+}
+
+// fetchInterval decides how often feed is polled: its own interval when one
+// is set, an interval derived from its recent posting rate when adaptive
+// scheduling is on, and the default otherwise.
+func fetchInterval(ctx context.Context, s *state, feed database.Feed) (time.Duration, error) {
+	if feed.FetchIntervalSeconds.Valid {
+		return time.Duration(feed.FetchIntervalSeconds.Int32) * time.Second, nil
+	}
+	if !feed.AdaptiveSchedule {
+		return defaultFetchInterval, nil
+	}
+	count, err := s.db.CountRecentPosts(ctx, database.CountRecentPostsParams{
+		FeedID:      feed.ID,
+		PublishedAt: time.Now().UTC().Add(-adaptiveWindow),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error counting recent posts: %v", err)
+	}
+	if count == 0 {
+		return maxAdaptiveInterval, nil
+	}
+	// Poll twice per average gap between posts.
+	interval := adaptiveWindow / time.Duration(count) / 2
+	return min(max(interval, minAdaptiveInterval), maxAdaptiveInterval), nil
+}
+
+// scheduleNextFetch records when feed is next due, along with the hints it
+// was scheduled by.
+func scheduleNextFetch(ctx context.Context, s *state, feed database.Feed, hints scheduleHints) error {
+	interval, err := fetchInterval(ctx, s, feed)
+	if err != nil {
+		return err
+	}
+	delay := nextFetchDelay(time.Now(), interval, hints)
+	params := database.ScheduleNextFetchParams{
+		ID:           feed.ID,
+		DelaySeconds: int32(delay / time.Second),
+		SkipHours:    []int32{},
+		SkipDays:     []int32{},
+	}
+	if hints.MinInterval > 0 {
+		params.MinIntervalSeconds = sql.NullInt32{Int32: int32(hints.MinInterval / time.Second), Valid: true}
+	}
+	for hour := 0; hour < 24; hour++ {
+		if hints.SkipHours[hour] {
+			params.SkipHours = append(params.SkipHours, int32(hour))
+		}
+	}
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if hints.SkipDays[day] {
+			params.SkipDays = append(params.SkipDays, int32(day))
+		}
+	}
+	err = s.db.ScheduleNextFetch(ctx, params)
+	if err != nil {
+		return fmt.Errorf("error scheduling next fetch: %v", err)
+	}
+	return nil
+}
+
+// parseSchedule parses a schedule argument: a duration, "adaptive", or
+// "default".
+func parseSchedule(arg string) (sql.NullInt32, bool, error) {
+	switch arg {
+	case "adaptive":
+		return sql.NullInt32{}, true, nil
+	case "default":
+		return sql.NullInt32{}, false, nil
+	}
+	interval, err := time.ParseDuration(arg)
+	if err != nil || interval < time.Minute {
+		return sql.NullInt32{}, false, fmt.Errorf("invalid interval %q: use a duration of at least 1m, adaptive or default", arg)
+	}
+	return sql.NullInt32{Int32: int32(interval / time.Second), Valid: true}, false, nil
+}
+
+func describeSchedule(interval sql.NullInt32, adaptive bool) string {
+	switch {
+	case interval.Valid:
+		return "every " + (time.Duration(interval.Int32) * time.Second).String()
+	case adaptive:
+		return "adaptively, based on how often it posts"
+	}
+	return "every " + defaultFetchInterval.String()
+}
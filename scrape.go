@@ -0,0 +1,346 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Specter242/Gator/internal/database"
+	"github.com/Specter242/Gator/internal/dateparse"
+)
+
+const (
+	defaultAggWorkers = 4
+	defaultAggPerHost = 2
+)
+
+// scrapeResult describes what a single feed scrape stored. Items that could
+// not be stored are counted in Skipped and items the retention policy drops
+// in Expired; Errors holds one entry per problem item, including items
+// stored with a fallback date.
+type scrapeResult struct {
+	StatusCode  int
+	NotModified bool
+	Inserted    int
+	Updated     int
+	Skipped     int
+	Expired     int
+	Errors      []error
+}
+
+func (r *scrapeResult) itemError(item RSSItem, err error) {
+	label := item.Title
+	if label == "" {
+		label = item.identity()
+	}
+	r.Errors = append(r.Errors, fmt.Errorf("item %q: %v", label, err))
+}
+
+// scrapeFeed fetches a claimed feed and upserts its items as posts. A bad
+// item is skipped and recorded rather than aborting the rest of the feed.
+// Items the feed's retention policy would prune aren't stored at all; def
+// is the default policy from the config file.
+func scrapeFeed(ctx context.Context, s *state, feed database.Feed, def retentionPolicy) (scrapeResult, error) {
+	var res scrapeResult
+	fetchedAt := time.Now().UTC()
+	result, err := fetchFeedConditional(ctx, feed.Url, feedValidators{
+		ETag:         feed.Etag.String,
+		LastModified: feed.LastModified.String,
+	})
+	if err != nil {
+		return res, fmt.Errorf("error fetching feed: %w", err)
+	}
+	res.StatusCode = result.StatusCode
+	if err := trackFeedMove(ctx, s, feed, result); err != nil {
+		log.Printf("Feed %s: %v", feed.Name, err)
+	}
+	if result.NotModified {
+		res.NotModified = true
+		return res, scheduleNextFetch(ctx, s, feed, storedHints(feed))
+	}
+	items := result.Feed.Channel.Item
+	fallbackDate := feedDate(result.Feed, fetchedAt)
+	pubTimes := make([]time.Time, len(items))
+	for i, item := range items {
+		pubTimes[i] = fallbackDate
+		if strings.TrimSpace(item.PubDate) != "" {
+			parsed, err := dateparse.Parse(item.PubDate)
+			if err != nil {
+				res.itemError(item, fmt.Errorf("%v; using %s", err, fallbackDate.Format(time.RFC3339)))
+			} else {
+				pubTimes[i] = parsed
+			}
+		}
+	}
+	retained := feedRetention(def, feed.RetentionMaxAgeSeconds, feed.RetentionMaxPosts).retained(pubTimes, fetchedAt)
+	for i, item := range items {
+		if !retained[i] {
+			res.Expired++
+			continue
+		}
+		guid := item.identity()
+		if item.Link != "" && item.Link != guid {
+			err := s.db.AdoptLegacyPost(ctx, database.AdoptLegacyPostParams{
+				Guid:   guid,
+				FeedID: feed.ID,
+				Url:    item.Link,
+			})
+			if err != nil {
+				res.Skipped++
+				res.itemError(item, fmt.Errorf("error adopting post stored by link: %v", err))
+				continue
+			}
+		}
+		post, err := s.db.UpsertPost(ctx, database.UpsertPostParams{
+			FeedID:      feed.ID,
+			Title:       item.Title,
+			Url:         item.Link,
+			Description: sql.NullString{String: item.Description, Valid: item.Description != ""},
+			PublishedAt: pubTimes[i],
+			Guid:        guid,
+			Content:     sql.NullString{String: item.Content, Valid: item.Content != ""},
+			Author:      sql.NullString{String: item.Author, Valid: item.Author != ""},
+			CommentsUrl: sql.NullString{String: item.CommentsURL, Valid: item.CommentsURL != ""},
+			Categories:  item.Categories,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			// Already stored and unchanged.
+			continue
+		}
+		if err != nil {
+			res.Skipped++
+			res.itemError(item, fmt.Errorf("error saving post: %v", err))
+			continue
+		}
+		if post.Inserted {
+			res.Inserted++
+		} else {
+			res.Updated++
+		}
+		if err := storeEnclosures(ctx, s, post.ID, item); err != nil {
+			res.itemError(item, err)
+		}
+	}
+	// Expired items were never attempted, so they don't count toward failure.
+	if attempted := len(items) - res.Expired; attempted > 0 && res.Skipped == attempted {
+		return res, fmt.Errorf("all %d items failed, first: %v", attempted, res.Errors[0])
+	}
+	// Only remember the validators once every item is stored, so a partly
+	// failed scrape is retried in full instead of answered with a 304.
+	validatorsChanged := result.Validators.ETag != feed.Etag.String || result.Validators.LastModified != feed.LastModified.String
+	if res.Skipped == 0 && validatorsChanged {
+		err = s.db.UpdateFeedValidators(ctx, database.UpdateFeedValidatorsParams{
+			ID:           feed.ID,
+			Etag:         sql.NullString{String: result.Validators.ETag, Valid: result.Validators.ETag != ""},
+			LastModified: sql.NullString{String: result.Validators.LastModified, Valid: result.Validators.LastModified != ""},
+		})
+		if err != nil {
+			return res, fmt.Errorf("error storing feed validators: %v", err)
+		}
+	}
+	return res, scheduleNextFetch(ctx, s, feed, hintsFromFeed(result.Feed))
+}
+
+// feedDate is the date used for items that don't carry one: the feed's own
+// publication or build date, or failing that the time it was fetched.
+func feedDate(feed *RSSFeed, fetchedAt time.Time) time.Time {
+	for _, value := range []string{feed.Channel.PubDate, feed.Channel.LastBuildDate} {
+		if t, err := dateparse.Parse(value); err == nil {
+			return t
+		}
+	}
+	return fetchedAt
+}
+
+// permanentMoveThreshold is how many fetches in a row must be permanently
+// redirected to the same URL before the feed's URL is updated, so a
+// misconfigured server doesn't move a feed on a single bad response.
+const permanentMoveThreshold = 3
+
+// trackFeedMove counts consecutive permanent redirects of feed to the same
+// URL and adopts that URL once the count reaches permanentMoveThreshold. A
+// move to a URL another feed already has is blocked rather than retried.
+func trackFeedMove(ctx context.Context, s *state, feed database.Feed, result *fetchResult) error {
+	if !result.MovedPermanently {
+		if !feed.MovedToUrl.Valid {
+			return nil
+		}
+		if err := s.db.ClearFeedMove(ctx, feed.ID); err != nil {
+			return fmt.Errorf("error clearing feed move: %v", err)
+		}
+		return nil
+	}
+	count, err := s.db.RecordFeedMove(ctx, database.RecordFeedMoveParams{
+		ID:         feed.ID,
+		MovedToUrl: sql.NullString{String: result.FinalURL, Valid: true},
+	})
+	if err != nil {
+		return fmt.Errorf("error recording feed move: %v", err)
+	}
+	if count < permanentMoveThreshold {
+		return nil
+	}
+	err = s.db.UpdateFeedURL(ctx, database.UpdateFeedURLParams{
+		ID:  feed.ID,
+		Url: result.FinalURL,
+	})
+	if pqErrorCode(err) == pqUniqueViolation {
+		if err := s.db.BlockFeedMove(ctx, feed.ID); err != nil {
+			return fmt.Errorf("error blocking feed move: %v", err)
+		}
+		return fmt.Errorf("not moving feed to %s: another feed already has that URL", result.FinalURL)
+	}
+	if err != nil {
+		return fmt.Errorf("error moving feed to %s: %v", result.FinalURL, err)
+	}
+	log.Printf("Feed %s moved permanently from %s to %s", feed.Name, feed.Url, result.FinalURL)
+	return nil
+}
+
+// aggregator scrapes every due feed in parallel. workers caps the number of
+// fetches in flight and perHost caps how many of those may hit one host.
+type aggregator struct {
+	s         *state
+	workers   int
+	perHost   int
+	retention retentionPolicy
+
+	mu    sync.Mutex
+	hosts map[string]chan struct{}
+}
+
+func newAggregator(s *state, workers, perHost int, retention retentionPolicy) *aggregator {
+	return &aggregator{
+		s:         s,
+		workers:   workers,
+		perHost:   perHost,
+		retention: retention,
+		hosts:     make(map[string]chan struct{}),
+	}
+}
+
+// cycleSummary totals the outcome of one aggregation cycle.
+type cycleSummary struct {
+	Feeds       int
+	Failed      int
+	NotModified int
+	Inserted    int
+	Updated     int
+	Skipped     int
+	Duration    time.Duration
+}
+
+func (c cycleSummary) String() string {
+	return fmt.Sprintf("Scraped %d feeds (%d failed, %d not modified): %d new, %d updated, %d skipped posts in %s",
+		c.Feeds, c.Failed, c.NotModified, c.Inserted, c.Updated, c.Skipped, c.Duration.Round(time.Millisecond))
+}
+
+type feedOutcome struct {
+	feed   database.Feed
+	result scrapeResult
+	err    error
+}
+
+// runCycle claims due feeds a batch at a time and scrapes them until none
+// are left. Claimed feeds are skipped by concurrent claimers, so several agg
+// processes can share one database.
+func (a *aggregator) runCycle(ctx context.Context) (cycleSummary, error) {
+	start := time.Now()
+	jobs := make(chan database.Feed)
+	outcomes := make(chan feedOutcome)
+
+	var wg sync.WaitGroup
+	for i := 0; i < a.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for feed := range jobs {
+				result, err := a.scrape(ctx, feed)
+				outcomes <- feedOutcome{feed: feed, result: result, err: err}
+			}
+		}()
+	}
+
+	var claimErr error
+	go func() {
+		defer close(jobs)
+		for {
+			feeds, err := a.s.db.ClaimFeedsToFetch(ctx, int32(a.workers))
+			if err != nil {
+				claimErr = fmt.Errorf("error claiming feeds to fetch: %v", err)
+				return
+			}
+			if len(feeds) == 0 {
+				return
+			}
+			for _, feed := range feeds {
+				jobs <- feed
+			}
+		}
+	}()
+	go func() {
+		wg.Wait()
+		close(outcomes)
+	}()
+
+	var summary cycleSummary
+	for outcome := range outcomes {
+		summary.Feeds++
+		switch {
+		case outcome.err != nil:
+			summary.Failed++
+			log.Printf("Feed %s: %v", outcome.feed.Name, outcome.err)
+		case outcome.result.NotModified:
+			summary.NotModified++
+		default:
+			summary.Inserted += outcome.result.Inserted
+			summary.Updated += outcome.result.Updated
+			summary.Skipped += outcome.result.Skipped
+			log.Printf("Feed %s: %d new, %d updated, %d skipped posts",
+				outcome.feed.Name, outcome.result.Inserted, outcome.result.Updated, outcome.result.Skipped)
+		}
+		for _, err := range outcome.result.Errors {
+			log.Printf("Feed %s: %v", outcome.feed.Name, err)
+		}
+	}
+	summary.Duration = time.Since(start)
+	return summary, claimErr
+}
+
+// scrape runs scrapeFeed once a slot for the feed's host is free and
+// records the outcome on the feed's health.
+func (a *aggregator) scrape(ctx context.Context, feed database.Feed) (scrapeResult, error) {
+	slot := a.hostSlot(feedHost(feed.Url))
+	slot <- struct{}{}
+	result, err := scrapeFeed(ctx, a.s, feed, a.retention)
+	<-slot
+	if healthErr := recordFeedHealth(ctx, a.s, feed, result, err); healthErr != nil {
+		log.Printf("Feed %s: %v", feed.Name, healthErr)
+	}
+	return result, err
+}
+
+func (a *aggregator) hostSlot(host string) chan struct{} {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	slot, ok := a.hosts[host]
+	if !ok {
+		slot = make(chan struct{}, a.perHost)
+		a.hosts[host] = slot
+	}
+	return slot
+}
+
+func feedHost(feedURL string) string {
+	u, err := url.Parse(feedURL)
+	if err != nil || u.Host == "" {
+		return feedURL
+	}
+	return u.Hostname()
+}
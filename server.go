@@ -0,0 +1,751 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Specter242/Gator/internal/database"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultServeAddr = ":8080"
+	apiDefaultLimit  = 20
+	apiMaxLimit      = 100
+	apiTokenPrefix   = "gator_"
+	apiMaxBodyBytes  = 1 << 20
+)
+
+// newAPIToken returns a fresh random token and the hash stored for it.
+// Only the hash is kept, so a token can't be shown again once created.
+func newAPIToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := apiTokenPrefix + hex.EncodeToString(buf)
+	return token, hashAPIToken(token), nil
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiError is an error response: the HTTP status, a stable code clients
+// can match on and a message for people.
+type apiError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *apiError) Error() string {
+	return e.Message
+}
+
+func errBadRequest(format string, args ...any) *apiError {
+	return &apiError{Status: http.StatusBadRequest, Code: "bad_request", Message: fmt.Sprintf(format, args...)}
+}
+
+func errNotFound(format string, args ...any) *apiError {
+	return &apiError{Status: http.StatusNotFound, Code: "not_found", Message: fmt.Sprintf(format, args...)}
+}
+
+func errUnauthorized(message string) *apiError {
+	return &apiError{Status: http.StatusUnauthorized, Code: "unauthorized", Message: message}
+}
+
+// apiServer serves the JSON API under /api/v1. Every endpoint acts as the
+// user whose token is given in an "Authorization: Bearer" header.
+type apiServer struct {
+	s *state
+}
+
+// apiHandlerFunc handles an authenticated request. Returned errors are
+// written as JSON error responses; an *apiError keeps its status and code
+// and anything else becomes a 500.
+type apiHandlerFunc func(w http.ResponseWriter, r *http.Request, user database.User) error
+
+// apiRoute dispatches a path's handlers by method, answering others with
+// 405 and an Allow header.
+type apiRoute map[string]apiHandlerFunc
+
+// newAPIHandler builds the handler for everything serve exposes: the JSON
+// API, the Fever API and the Google Reader API. It is an ordinary
+// http.Handler, so it can be exercised with httptest.
+func newAPIHandler(s *state) http.Handler {
+	srv := &apiServer{s: s}
+	mux := http.NewServeMux()
+	routes := map[string]apiRoute{
+		"/api/v1/me":              {"GET": srv.getMe},
+		"/api/v1/users":           {"GET": srv.listUsers},
+		"/api/v1/feeds":           {"GET": srv.listFeeds, "POST": srv.createFeed},
+		"/api/v1/follows":         {"GET": srv.listFollows, "POST": srv.createFollow},
+		"/api/v1/follows/{feed}":  {"DELETE": srv.deleteFollow},
+		"/api/v1/posts":           {"GET": srv.listPosts},
+		"/api/v1/posts/{id}":      {"GET": srv.getPost},
+		"/api/v1/posts/{id}/read": {"PUT": srv.markRead, "DELETE": srv.markUnread},
+		"/api/v1/posts/{id}/star": {"PUT": srv.star, "DELETE": srv.unstar},
+		"/api/v1/starred":         {"GET": srv.listStarred},
+	}
+	for pattern, route := range routes {
+		mux.Handle(pattern, srv.serveRoute(route))
+	}
+	mux.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		writeAPIError(w, errNotFound("no such endpoint: %s", r.URL.Path))
+	})
+	fever := newFeverHandler(s)
+	mux.Handle("/fever", fever)
+	mux.Handle("/fever/", fever)
+	greader := newGReaderHandler(s)
+	mux.Handle("/accounts/ClientLogin", greader)
+	mux.Handle("/reader/api/0/", greader)
+	return mux
+}
+
+func (srv *apiServer) serveRoute(route apiRoute) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		handler, ok := route[r.Method]
+		if !ok {
+			methods := make([]string, 0, len(route))
+			for method := range route {
+				methods = append(methods, method)
+			}
+			slices.Sort(methods)
+			w.Header().Set("Allow", strings.Join(methods, ", "))
+			writeAPIError(w, &apiError{
+				Status:  http.StatusMethodNotAllowed,
+				Code:    "method_not_allowed",
+				Message: fmt.Sprintf("%s is not allowed here", r.Method),
+			})
+			return
+		}
+		user, err := srv.authenticate(r)
+		if err == nil {
+			err = handler(w, r, user)
+		}
+		if err != nil {
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) {
+				log.Printf("%s %s: %v", r.Method, r.URL.Path, err)
+				apiErr = &apiError{Status: http.StatusInternalServerError, Code: "internal", Message: "internal server error"}
+			}
+			if apiErr.Status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="gator"`)
+			}
+			writeAPIError(w, apiErr)
+		}
+	})
+}
+
+func (srv *apiServer) authenticate(r *http.Request) (database.User, error) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	token = strings.TrimSpace(token)
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return database.User{}, errUnauthorized("missing bearer token")
+	}
+	user, err := srv.s.db.GetUserByAPIToken(r.Context(), hashAPIToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, errUnauthorized("invalid or revoked token")
+	}
+	if err != nil {
+		return user, fmt.Errorf("error checking token: %v", err)
+	}
+	return user, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("error writing response: %v", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, err *apiError) {
+	writeJSON(w, err.Status, map[string]*apiError{"error": err})
+}
+
+// apiPage is the pagination of a list response. NextOffset is set while
+// there may be more results.
+type apiPage struct {
+	Limit      int  `json:"limit"`
+	Offset     int  `json:"offset"`
+	NextOffset *int `json:"next_offset"`
+}
+
+type apiList struct {
+	Data       any     `json:"data"`
+	Pagination apiPage `json:"pagination"`
+}
+
+// pageParams reads the limit and offset query parameters.
+func pageParams(r *http.Request) (apiPage, error) {
+	page := apiPage{Limit: apiDefaultLimit}
+	query := r.URL.Query()
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > apiMaxLimit {
+			return page, errBadRequest("limit must be between 1 and %d", apiMaxLimit)
+		}
+		page.Limit = n
+	}
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 || n > math.MaxInt32 {
+			return page, errBadRequest("offset must be a non-negative number")
+		}
+		page.Offset = n
+	}
+	return page, nil
+}
+
+// writeList writes one page of results. A full page means there may be
+// another after it.
+func writeList[T any](w http.ResponseWriter, page apiPage, items []T) {
+	if items == nil {
+		items = []T{}
+	}
+	if len(items) == page.Limit {
+		next := page.Offset + page.Limit
+		page.NextOffset = &next
+	}
+	writeJSON(w, http.StatusOK, apiList{Data: items, Pagination: page})
+}
+
+func writeData(w http.ResponseWriter, status int, data any) {
+	writeJSON(w, status, map[string]any{"data": data})
+}
+
+// decodeBody reads a JSON request body into v, rejecting unknown fields.
+func decodeBody(w http.ResponseWriter, r *http.Request, v any) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, apiMaxBodyBytes))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return errBadRequest("invalid request body: %v", err)
+	}
+	return nil
+}
+
+func pathID(r *http.Request, name string) (int32, error) {
+	n, err := strconv.ParseInt(r.PathValue(name), 10, 32)
+	if err != nil || n <= 0 {
+		return 0, errBadRequest("invalid %s: %s", name, r.PathValue(name))
+	}
+	return int32(n), nil
+}
+
+// pqErrorCode returns the SQLSTATE of a PostgreSQL error, or "".
+func pqErrorCode(err error) pq.ErrorCode {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code
+	}
+	return ""
+}
+
+const (
+	pqForeignKeyViolation = "23503"
+	pqUniqueViolation     = "23505"
+)
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+type apiUser struct {
+	ID        int32     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func newAPIUser(user database.User) apiUser {
+	return apiUser{ID: user.ID, Name: user.Name, CreatedAt: user.CreatedAt}
+}
+
+type apiFeed struct {
+	ID            int32      `json:"id"`
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	UserID        int32      `json:"user_id"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	Health        string     `json:"health"`
+}
+
+type apiFollow struct {
+	ID          int32     `json:"id"`
+	FeedID      int32     `json:"feed_id"`
+	FeedName    string    `json:"feed_name"`
+	UnreadCount int64     `json:"unread_count"`
+	Folders     []string  `json:"folders"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type apiPost struct {
+	ID          int32      `json:"id"`
+	FeedID      int32      `json:"feed_id"`
+	FeedName    string     `json:"feed_name"`
+	Title       string     `json:"title"`
+	URL         string     `json:"url"`
+	Description string     `json:"description,omitempty"`
+	Content     string     `json:"content,omitempty"`
+	Author      string     `json:"author,omitempty"`
+	CommentsURL string     `json:"comments_url,omitempty"`
+	Categories  []string   `json:"categories"`
+	PublishedAt time.Time  `json:"published_at"`
+	ReadAt      *time.Time `json:"read_at"`
+	StarredAt   *time.Time `json:"starred_at"`
+}
+
+func newAPIPost(post database.GetPostsForUserRow) apiPost {
+	categories := post.Categories
+	if categories == nil {
+		categories = []string{}
+	}
+	return apiPost{
+		ID:          post.ID,
+		FeedID:      post.FeedID,
+		FeedName:    post.FeedName,
+		Title:       post.Title,
+		URL:         post.Url,
+		Description: post.Description.String,
+		Content:     post.Content.String,
+		Author:      post.Author.String,
+		CommentsURL: post.CommentsUrl.String,
+		Categories:  categories,
+		PublishedAt: post.PublishedAt,
+		ReadAt:      timePtr(post.ReadAt),
+		StarredAt:   timePtr(post.StarredAt),
+	}
+}
+
+func (srv *apiServer) getMe(w http.ResponseWriter, r *http.Request, user database.User) error {
+	writeData(w, http.StatusOK, newAPIUser(user))
+	return nil
+}
+
+func (srv *apiServer) listUsers(w http.ResponseWriter, r *http.Request, user database.User) error {
+	page, err := pageParams(r)
+	if err != nil {
+		return err
+	}
+	users, err := srv.s.db.GetUsers(r.Context(), database.GetUsersParams{
+		Limit:  int32(page.Limit),
+		Offset: int32(page.Offset),
+	})
+	if err != nil {
+		return fmt.Errorf("error getting users: %v", err)
+	}
+	items := make([]apiUser, 0, len(users))
+	for _, u := range users {
+		items = append(items, newAPIUser(u))
+	}
+	writeList(w, page, items)
+	return nil
+}
+
+func (srv *apiServer) listFeeds(w http.ResponseWriter, r *http.Request, user database.User) error {
+	page, err := pageParams(r)
+	if err != nil {
+		return err
+	}
+	feeds, err := srv.s.db.GetFeeds(r.Context(), database.GetFeedsParams{
+		Limit:  int32(page.Limit),
+		Offset: int32(page.Offset),
+	})
+	if err != nil {
+		return fmt.Errorf("error getting feeds: %v", err)
+	}
+	items := make([]apiFeed, 0, len(feeds))
+	for _, feed := range feeds {
+		items = append(items, apiFeed{
+			ID:            feed.ID,
+			Name:          feed.Name,
+			URL:           feed.Url,
+			UserID:        feed.UserID,
+			CreatedAt:     feed.CreatedAt,
+			LastFetchedAt: timePtr(feed.LastFetchedAt),
+			Health:        feedHealth(feed),
+		})
+	}
+	writeList(w, page, items)
+	return nil
+}
+
+// createFeed adds a feed, as addfeed does, and follows it. The URL must be
+// a working feed; unlike addfeed it doesn't search web pages for feeds.
+func (srv *apiServer) createFeed(w http.ResponseWriter, r *http.Request, user database.User) error {
+	var body struct {
+		Name string `json:"name"`
+		URL  string `json:"url"`
+	}
+	if err := decodeBody(w, r, &body); err != nil {
+		return err
+	}
+	body.Name = strings.TrimSpace(body.Name)
+	body.URL = strings.TrimSpace(body.URL)
+	if body.Name == "" || body.URL == "" {
+		return errBadRequest("name and url are required")
+	}
+	if _, err := fetchFeed(r.Context(), body.URL); err != nil {
+		return &apiError{Status: http.StatusUnprocessableEntity, Code: "invalid_feed", Message: err.Error()}
+	}
+	feed, err := srv.s.db.CreateFeed(r.Context(), database.CreateFeedParams{
+		Name:   body.Name,
+		Url:    body.URL,
+		UserID: user.ID,
+	})
+	if pqErrorCode(err) == pqUniqueViolation {
+		return &apiError{Status: http.StatusConflict, Code: "conflict", Message: "a feed with this name or url already exists"}
+	}
+	if err != nil {
+		return fmt.Errorf("error creating feed: %v", err)
+	}
+	_, err = srv.s.db.FollowFeed(r.Context(), database.FollowFeedParams{
+		UserID: user.ID,
+		FeedID: feed.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error following feed: %v", err)
+	}
+	writeData(w, http.StatusCreated, apiFeed{
+		ID:        feed.ID,
+		Name:      feed.Name,
+		URL:       feed.Url,
+		UserID:    feed.UserID,
+		CreatedAt: feed.CreatedAt,
+		Health:    "new",
+	})
+	return nil
+}
+
+func (srv *apiServer) listFollows(w http.ResponseWriter, r *http.Request, user database.User) error {
+	page, err := pageParams(r)
+	if err != nil {
+		return err
+	}
+	follows, err := srv.s.db.GetFeedFollowsForUser(r.Context(), database.GetFeedFollowsForUserParams{
+		UserID: user.ID,
+		Limit:  int32(page.Limit),
+		Offset: int32(page.Offset),
+	})
+	if err != nil {
+		return fmt.Errorf("error getting follows: %v", err)
+	}
+	items := make([]apiFollow, 0, len(follows))
+	for _, follow := range follows {
+		folders := follow.Folders
+		if folders == nil {
+			folders = []string{}
+		}
+		items = append(items, apiFollow{
+			ID:          follow.ID,
+			FeedID:      follow.FeedID,
+			FeedName:    follow.FeedName,
+			UnreadCount: follow.UnreadCount,
+			Folders:     folders,
+			CreatedAt:   follow.CreatedAt,
+		})
+	}
+	writeList(w, page, items)
+	return nil
+}
+
+// createFollow follows a feed already in Gator, given by feed_id or url.
+// It answers 201 for a new follow and 200 if the user already followed it.
+func (srv *apiServer) createFollow(w http.ResponseWriter, r *http.Request, user database.User) error {
+	var body struct {
+		FeedID int32  `json:"feed_id"`
+		URL    string `json:"url"`
+	}
+	if err := decodeBody(w, r, &body); err != nil {
+		return err
+	}
+	feedID := body.FeedID
+	switch {
+	case feedID != 0 && body.URL != "":
+		return errBadRequest("give either feed_id or url, not both")
+	case body.URL != "":
+		feed, err := srv.s.db.GetFeedByURL(r.Context(), strings.TrimSpace(body.URL))
+		if errors.Is(err, sql.ErrNoRows) {
+			return errNotFound("no feed with url %s; add it with POST /api/v1/feeds", body.URL)
+		}
+		if err != nil {
+			return fmt.Errorf("error getting feed: %v", err)
+		}
+		feedID = feed.ID
+	case feedID == 0:
+		return errBadRequest("feed_id or url is required")
+	}
+	follow, err := srv.s.db.FollowFeed(r.Context(), database.FollowFeedParams{
+		UserID: user.ID,
+		FeedID: feedID,
+	})
+	if pqErrorCode(err) == pqForeignKeyViolation {
+		return errNotFound("feed %d not found", feedID)
+	}
+	if err != nil {
+		return fmt.Errorf("error following feed: %v", err)
+	}
+	status := http.StatusOK
+	if follow.Inserted {
+		status = http.StatusCreated
+	}
+	writeData(w, status, map[string]int32{"id": follow.ID, "feed_id": feedID})
+	return nil
+}
+
+func (srv *apiServer) deleteFollow(w http.ResponseWriter, r *http.Request, user database.User) error {
+	feedID, err := pathID(r, "feed")
+	if err != nil {
+		return err
+	}
+	_, err = srv.s.db.GetFeedFollow(r.Context(), database.GetFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feedID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return errNotFound("you don't follow feed %d", feedID)
+	}
+	if err != nil {
+		return fmt.Errorf("error getting follow: %v", err)
+	}
+	err = srv.s.db.RemoveFeedFollow(r.Context(), database.RemoveFeedFollowParams{
+		UserID: user.ID,
+		FeedID: feedID,
+	})
+	if err != nil {
+		return fmt.Errorf("error removing follow: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+// listPosts lists posts from the user's followed feeds, newest first,
+// filtered like browse by the feed, author, category, folder and unread
+// query parameters.
+func (srv *apiServer) listPosts(w http.ResponseWriter, r *http.Request, user database.User) error {
+	page, err := pageParams(r)
+	if err != nil {
+		return err
+	}
+	query := r.URL.Query()
+	optional := func(name string) sql.NullString {
+		v := strings.TrimSpace(query.Get(name))
+		return sql.NullString{String: v, Valid: v != ""}
+	}
+	var unread bool
+	if v := query.Get("unread"); v != "" {
+		unread, err = strconv.ParseBool(v)
+		if err != nil {
+			return errBadRequest("unread must be true or false")
+		}
+	}
+	posts, err := srv.s.db.GetPostsForUser(r.Context(), database.GetPostsForUserParams{
+		UserID:     user.ID,
+		Feed:       optional("feed"),
+		Author:     optional("author"),
+		Category:   optional("category"),
+		Folder:     optional("folder"),
+		UnreadOnly: unread,
+		Limit:      int32(page.Limit),
+		Offset:     int32(page.Offset),
+	})
+	if err != nil {
+		return fmt.Errorf("error getting posts: %v", err)
+	}
+	items := make([]apiPost, 0, len(posts))
+	for _, post := range posts {
+		items = append(items, newAPIPost(post))
+	}
+	writeList(w, page, items)
+	return nil
+}
+
+// followedPost looks up the post in the request path among the user's
+// followed feeds.
+func (srv *apiServer) followedPost(r *http.Request, user database.User) (database.GetPostForUserRow, error) {
+	postID, err := pathID(r, "id")
+	if err != nil {
+		return database.GetPostForUserRow{}, err
+	}
+	post, err := srv.s.db.GetPostForUser(r.Context(), database.GetPostForUserParams{
+		ID:     postID,
+		UserID: user.ID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return post, errNotFound("post %d not found in your followed feeds", postID)
+	}
+	if err != nil {
+		return post, fmt.Errorf("error getting post: %v", err)
+	}
+	return post, nil
+}
+
+// getPost returns a post without marking it read; use PUT .../read for that.
+func (srv *apiServer) getPost(w http.ResponseWriter, r *http.Request, user database.User) error {
+	post, err := srv.followedPost(r, user)
+	if err != nil {
+		return err
+	}
+	writeData(w, http.StatusOK, newAPIPost(database.GetPostsForUserRow(post)))
+	return nil
+}
+
+func (srv *apiServer) markRead(w http.ResponseWriter, r *http.Request, user database.User) error {
+	post, err := srv.followedPost(r, user)
+	if err != nil {
+		return err
+	}
+	err = srv.s.db.MarkPostRead(r.Context(), database.MarkPostReadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error marking post read: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (srv *apiServer) markUnread(w http.ResponseWriter, r *http.Request, user database.User) error {
+	post, err := srv.followedPost(r, user)
+	if err != nil {
+		return err
+	}
+	_, err = srv.s.db.MarkPostUnread(r.Context(), database.MarkPostUnreadParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error marking post unread: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (srv *apiServer) star(w http.ResponseWriter, r *http.Request, user database.User) error {
+	post, err := srv.followedPost(r, user)
+	if err != nil {
+		return err
+	}
+	err = srv.s.db.StarPost(r.Context(), database.StarPostParams{
+		UserID: user.ID,
+		PostID: post.ID,
+	})
+	if err != nil {
+		return fmt.Errorf("error starring post: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (srv *apiServer) unstar(w http.ResponseWriter, r *http.Request, user database.User) error {
+	postID, err := pathID(r, "id")
+	if err != nil {
+		return err
+	}
+	_, err = srv.s.db.UnstarPost(r.Context(), database.UnstarPostParams{
+		UserID: user.ID,
+		PostID: postID,
+	})
+	if err != nil {
+		return fmt.Errorf("error unstarring post: %v", err)
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
+func (srv *apiServer) listStarred(w http.ResponseWriter, r *http.Request, user database.User) error {
+	page, err := pageParams(r)
+	if err != nil {
+		return err
+	}
+	posts, err := srv.s.db.GetStarredPostsForUser(r.Context(), database.GetStarredPostsForUserParams{
+		UserID: user.ID,
+		Limit:  int32(page.Limit),
+		Offset: int32(page.Offset),
+	})
+	if err != nil {
+		return fmt.Errorf("error getting starred posts: %v", err)
+	}
+	items := make([]apiPost, 0, len(posts))
+	for _, post := range posts {
+		items = append(items, newAPIPost(database.GetPostsForUserRow{
+			ID:          post.ID,
+			Title:       post.Title,
+			Url:         post.Url,
+			Description: post.Description,
+			PublishedAt: post.PublishedAt,
+			FeedID:      post.FeedID,
+			Content:     post.Content,
+			Author:      post.Author,
+			CommentsUrl: post.CommentsUrl,
+			Categories:  post.Categories,
+			FeedName:    post.FeedName,
+			StarredAt:   post.StarredAt,
+		}))
+	}
+	writeList(w, page, items)
+	return nil
+}
+
+// logRequests logs each request with its status and how long it took.
+func logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		log.Printf("%s %s %d %s", r.Method, r.URL.Path, rec.status, time.Since(start).Round(time.Millisecond))
+	})
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (rec *statusRecorder) WriteHeader(status int) {
+	rec.status = status
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+// serveAPI runs the API server on addr until ctx is cancelled, then lets
+// requests in flight finish.
+func serveAPI(ctx context.Context, s *state, addr string) error {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           logRequests(newAPIHandler(s)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	return server.Shutdown(shutdownCtx)
+}
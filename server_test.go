@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// The handler is built without a database: every request here is answered
+// before a query would run.
+func serveTestRequest(t *testing.T, method, target string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, nil)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	rec := httptest.NewRecorder()
+	newAPIHandler(&state{}).ServeHTTP(rec, req)
+	return rec
+}
+
+func decodeAPIError(t *testing.T, rec *httptest.ResponseRecorder) apiError {
+	t.Helper()
+	var body struct {
+		Error apiError `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("error decoding error response: %v", err)
+	}
+	return body.Error
+}
+
+func TestAPIRejectsMissingToken(t *testing.T) {
+	for _, auth := range []string{"", "Bearer", "Bearer   ", "Basic dXNlcjpwYXNz", "gator_abc"} {
+		header := http.Header{}
+		if auth != "" {
+			header.Set("Authorization", auth)
+		}
+		rec := serveTestRequest(t, "GET", "/api/v1/me", header)
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: status %d, want 401", auth, rec.Code)
+			continue
+		}
+		if got := rec.Header().Get("WWW-Authenticate"); got != `Bearer realm="gator"` {
+			t.Errorf("Authorization %q: WWW-Authenticate %q", auth, got)
+		}
+		if apiErr := decodeAPIError(t, rec); apiErr.Code != "unauthorized" {
+			t.Errorf("Authorization %q: code %q, want unauthorized", auth, apiErr.Code)
+		}
+	}
+}
+
+func TestAPIMethodNotAllowed(t *testing.T) {
+	tests := []struct {
+		method, target, allow string
+	}{
+		{"DELETE", "/api/v1/me", "GET"},
+		{"PUT", "/api/v1/feeds", "GET, POST"},
+		{"GET", "/api/v1/follows/1", "DELETE"},
+		{"POST", "/api/v1/posts/1/read", "DELETE, PUT"},
+	}
+	for _, tt := range tests {
+		rec := serveTestRequest(t, tt.method, tt.target, nil)
+		if rec.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s %s: status %d, want 405", tt.method, tt.target, rec.Code)
+			continue
+		}
+		if got := rec.Header().Get("Allow"); got != tt.allow {
+			t.Errorf("%s %s: Allow %q, want %q", tt.method, tt.target, got, tt.allow)
+		}
+		if apiErr := decodeAPIError(t, rec); apiErr.Code != "method_not_allowed" {
+			t.Errorf("%s %s: code %q, want method_not_allowed", tt.method, tt.target, apiErr.Code)
+		}
+	}
+}
+
+func TestAPIUnknownEndpoint(t *testing.T) {
+	rec := serveTestRequest(t, "GET", "/api/v1/nope", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404", rec.Code)
+	}
+	if apiErr := decodeAPIError(t, rec); apiErr.Code != "not_found" {
+		t.Errorf("code %q, want not_found", apiErr.Code)
+	}
+}
+
+func TestPageParams(t *testing.T) {
+	tests := []struct {
+		query  string
+		want   apiPage
+		wantOK bool
+	}{
+		{"", apiPage{Limit: apiDefaultLimit}, true},
+		{"limit=1", apiPage{Limit: 1}, true},
+		{"limit=100&offset=40", apiPage{Limit: 100, Offset: 40}, true},
+		{"offset=0", apiPage{Limit: apiDefaultLimit}, true},
+		{"limit=0", apiPage{}, false},
+		{"limit=101", apiPage{}, false},
+		{"limit=-5", apiPage{}, false},
+		{"limit=ten", apiPage{}, false},
+		{"offset=-1", apiPage{}, false},
+		{"offset=2147483648", apiPage{}, false},
+	}
+	for _, tt := range tests {
+		page, err := pageParams(httptest.NewRequest("GET", "/api/v1/posts?"+tt.query, nil))
+		if !tt.wantOK {
+			var apiErr *apiError
+			if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadRequest {
+				t.Errorf("pageParams(%q) error %v, want a 400", tt.query, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("pageParams(%q): %v", tt.query, err)
+			continue
+		}
+		if page.Limit != tt.want.Limit || page.Offset != tt.want.Offset || page.NextOffset != nil {
+			t.Errorf("pageParams(%q) = %+v, want %+v", tt.query, page, tt.want)
+		}
+	}
+}
+
+func TestWriteListNextOffset(t *testing.T) {
+	tests := []struct {
+		items []int
+		page  apiPage
+		next  *int
+	}{
+		{nil, apiPage{Limit: 2}, nil},
+		{[]int{1}, apiPage{Limit: 2, Offset: 4}, nil},
+		{[]int{1, 2}, apiPage{Limit: 2, Offset: 4}, intPtr(6)},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		writeList(rec, tt.page, tt.items)
+		var body struct {
+			Data       []int   `json:"data"`
+			Pagination apiPage `json:"pagination"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatalf("error decoding list: %v", err)
+		}
+		if body.Data == nil {
+			t.Errorf("%v: data is null, want a list", tt.items)
+		}
+		got := body.Pagination.NextOffset
+		switch {
+		case tt.next == nil && got != nil:
+			t.Errorf("%v: next_offset %d, want none", tt.items, *got)
+		case tt.next != nil && (got == nil || *got != *tt.next):
+			t.Errorf("%v: next_offset %v, want %d", tt.items, got, *tt.next)
+		}
+	}
+}
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestGReaderClientLoginRequiresPost(t *testing.T) {
+	rec := serveTestRequest(t, "GET", "/accounts/ClientLogin?Email=jo&Passwd=secret", nil)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("status %d, want 405", rec.Code)
+	}
+	if got := rec.Header().Get("Allow"); got != "POST" {
+		t.Errorf("Allow %q, want POST", got)
+	}
+}
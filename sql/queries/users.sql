@@ -20,8 +20,8 @@ ORDER BY created_at DESC
 LIMIT $1 OFFSET $2;
 
 -- name: CreateFeed :one
-INSERT INTO feeds (name, url, user_id)
-VALUES ($1, $2, $3)
+INSERT INTO feeds (name, url, user_id, fetch_interval_seconds, adaptive_schedule)
+VALUES ($1, $2, $3, $4, $5)
 RETURNING id, created_at, updated_at, name, url, user_id;
 
 -- name: CreateFeedFollow :many
@@ -42,7 +42,18 @@ JOIN users ON inserted_feed_follows.user_id = users.id;
 SELECT
     feed_follows.*,
     feeds.name AS feed_name,
-    users.name AS user_name
+    users.name AS user_name,
+    (
+        SELECT COUNT(*) FROM posts
+        LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+        WHERE posts.feed_id = feed_follows.feed_id AND post_states.read_at IS NULL
+    ) AS unread_count,
+    COALESCE((
+        SELECT array_agg(folders.name ORDER BY folders.name)
+        FROM feed_follow_folders
+        JOIN folders ON feed_follow_folders.folder_id = folders.id
+        WHERE feed_follow_folders.feed_follow_id = feed_follows.id
+    ), '{}')::text[] AS folders
 FROM feed_follows
 JOIN feeds ON feed_follows.feed_id = feeds.id
 JOIN users ON feed_follows.user_id = users.id
@@ -59,31 +70,574 @@ LIMIT $1 OFFSET $2;
 DELETE FROM feed_follows
 WHERE user_id = $1 AND feed_id = $2;
 
--- name: LastFetchedAt :exec
+-- name: GetFeedByNameOrURL :one
+SELECT * FROM feeds
+WHERE name = sqlc.arg(name_or_url) OR url = sqlc.arg(name_or_url);
+
+-- name: ClaimFeedsToFetch :many
+-- Claimed feeds are leased for an hour so a crashed scrape is retried later
+-- rather than on every cycle; ScheduleNextFetch replaces the lease.
 UPDATE feeds
 SET last_fetched_at = NOW(),
+    next_fetch_at = NOW() + INTERVAL '1 hour',
+    updated_at = NOW()
+WHERE id IN (
+    SELECT id FROM feeds
+    WHERE disabled_at IS NULL
+      AND (next_fetch_at IS NULL OR next_fetch_at <= NOW())
+    ORDER BY next_fetch_at ASC NULLS FIRST
+    LIMIT $1
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: ScheduleNextFetch :exec
+-- The delay is added to the database's clock, so the schedule doesn't
+-- depend on the time zone of the host running agg. The publisher's hints
+-- are kept for scheduling after 304 responses, which don't repeat them.
+UPDATE feeds
+SET next_fetch_at = NOW() + make_interval(secs => sqlc.arg(delay_seconds)::int),
+    min_interval_seconds = sqlc.narg(min_interval_seconds),
+    skip_hours = sqlc.arg(skip_hours)::int[],
+    skip_days = sqlc.arg(skip_days)::int[]
+WHERE id = sqlc.arg(id);
+
+-- name: SetFeedSchedule :exec
+UPDATE feeds
+SET fetch_interval_seconds = $2,
+    adaptive_schedule = $3,
+    next_fetch_at = NULL,
     updated_at = NOW()
 WHERE id = $1;
 
--- name: GetNextFeedToFetch :one
-SELECT * FROM feeds
-WHERE last_fetched_at IS NULL OR last_fetched_at < NOW() - INTERVAL '1 hour'
-ORDER BY last_fetched_at ASC
-LIMIT 1;
+-- name: RecordFeedSuccess :exec
+UPDATE feeds
+SET consecutive_failures = 0,
+    last_error = NULL,
+    last_status = $2,
+    last_success_at = NOW(),
+    last_scrape_inserted = $3,
+    last_scrape_updated = $4,
+    last_scrape_skipped = $5,
+    last_scrape_errors = $6,
+    updated_at = NOW()
+WHERE id = $1;
 
--- name: CreatePost :one
-INSERT INTO posts (title, url, description, published_at, feed_id)
-VALUES ($1, $2, $3, $4, $5)
-RETURNING id, created_at, updated_at, title, url, description, published_at, feed_id;
+-- name: RecordFeedFailure :one
+UPDATE feeds
+SET consecutive_failures = consecutive_failures + 1,
+    last_error = sqlc.arg(last_error),
+    last_status = sqlc.arg(last_status),
+    next_fetch_at = NOW() + make_interval(secs => sqlc.arg(backoff_seconds)::int),
+    disabled_at = CASE
+        WHEN consecutive_failures + 1 >= sqlc.arg(max_failures)::int THEN NOW()
+        ELSE disabled_at
+    END,
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING consecutive_failures, disabled_at;
+
+-- name: RecordFeedMove :one
+-- A move blocked by BlockFeedMove keeps a count of 0 for as long as the
+-- feed redirects to the same URL.
+UPDATE feeds
+SET moved_count = CASE
+        WHEN moved_to_url = sqlc.arg(moved_to_url) AND moved_count = 0 THEN 0
+        WHEN moved_to_url = sqlc.arg(moved_to_url) THEN moved_count + 1
+        ELSE 1
+    END,
+    moved_to_url = sqlc.arg(moved_to_url)
+WHERE id = sqlc.arg(id)
+RETURNING moved_count;
+
+-- name: ClearFeedMove :exec
+UPDATE feeds
+SET moved_to_url = NULL,
+    moved_count = 0
+WHERE id = $1;
+
+-- name: BlockFeedMove :exec
+-- Keeps the URL a feed redirects to but stops counting towards the move,
+-- for when another feed already has that URL.
+UPDATE feeds
+SET moved_count = 0
+WHERE id = $1;
+
+-- name: UpdateFeedURL :exec
+UPDATE feeds
+SET url = $2,
+    moved_to_url = NULL,
+    moved_count = 0,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: EnableFeed :exec
+UPDATE feeds
+SET disabled_at = NULL,
+    consecutive_failures = 0,
+    next_fetch_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: CountRecentPosts :one
+SELECT COUNT(*) FROM posts
+WHERE feed_id = $1 AND published_at > $2;
+
+-- name: UpdateFeedValidators :exec
+UPDATE feeds
+SET etag = $2,
+    last_modified = $3,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: UpsertPost :one
+INSERT INTO posts (title, url, description, published_at, feed_id, guid, content, author, comments_url, categories)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+ON CONFLICT (feed_id, guid) DO UPDATE
+SET title = EXCLUDED.title,
+    url = EXCLUDED.url,
+    description = EXCLUDED.description,
+    content = EXCLUDED.content,
+    author = EXCLUDED.author,
+    comments_url = EXCLUDED.comments_url,
+    categories = EXCLUDED.categories,
+    updated_at = NOW()
+WHERE posts.title IS DISTINCT FROM EXCLUDED.title
+   OR posts.url IS DISTINCT FROM EXCLUDED.url
+   OR posts.description IS DISTINCT FROM EXCLUDED.description
+   OR posts.content IS DISTINCT FROM EXCLUDED.content
+   OR posts.author IS DISTINCT FROM EXCLUDED.author
+   OR posts.comments_url IS DISTINCT FROM EXCLUDED.comments_url
+   OR posts.categories IS DISTINCT FROM EXCLUDED.categories
+RETURNING id, (xmax = 0) AS inserted;
+
+-- name: AdoptLegacyPost :exec
+-- Posts stored before guids were tracked are keyed by their link. This
+-- moves such a post over to the item's guid so the next upsert updates it
+-- instead of storing the item a second time.
+UPDATE posts
+SET guid = sqlc.arg(guid)
+WHERE posts.feed_id = sqlc.arg(feed_id)
+  AND posts.guid = sqlc.arg(url)
+  AND posts.guid <> sqlc.arg(guid)
+  AND NOT EXISTS (
+      SELECT 1 FROM posts AS existing
+      WHERE existing.feed_id = sqlc.arg(feed_id)
+        AND existing.guid = sqlc.arg(guid)
+  );
 
 -- name: GetPostsForUser :many
 SELECT
-    posts.*,
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description,
+    posts.published_at, posts.feed_id, posts.guid, posts.content, posts.author, posts.comments_url,
+    posts.categories,
     feeds.name AS feed_name,
-    users.name AS user_name
+    post_states.read_at,
+    post_states.starred_at
 FROM posts
 JOIN feeds ON posts.feed_id = feeds.id
-JOIN users ON feeds.user_id = users.id
-WHERE users.id = $1
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+  AND (sqlc.narg(feed)::text IS NULL OR feeds.name = sqlc.narg(feed) OR feeds.url = sqlc.narg(feed))
+  AND (sqlc.narg(author)::text IS NULL OR posts.author ILIKE '%' || sqlc.narg(author) || '%')
+  AND (sqlc.narg(category)::text IS NULL OR EXISTS (
+      SELECT 1 FROM unnest(posts.categories) AS category
+      WHERE lower(category) = lower(sqlc.narg(category))
+  ))
+  AND (sqlc.narg(folder)::text IS NULL OR EXISTS (
+      SELECT 1 FROM feed_follow_folders
+      JOIN folders ON feed_follow_folders.folder_id = folders.id
+      WHERE feed_follow_folders.feed_follow_id = feed_follows.id
+        AND (folders.name = sqlc.narg(folder) OR left(folders.name, length(sqlc.narg(folder)) + 1) = sqlc.narg(folder) || '/')
+  ))
+  AND (NOT sqlc.arg(unread_only)::bool OR post_states.read_at IS NULL)
 ORDER BY posts.published_at DESC
-LIMIT $2 OFFSET $3;
\ No newline at end of file
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
+-- name: UpsertEnclosure :exec
+INSERT INTO enclosures (post_id, url, mime_type, length, duration_seconds)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (post_id, url) DO UPDATE
+SET mime_type = EXCLUDED.mime_type,
+    length = EXCLUDED.length,
+    duration_seconds = EXCLUDED.duration_seconds,
+    updated_at = NOW();
+
+-- name: GetEnclosuresForPosts :many
+SELECT * FROM enclosures
+WHERE post_id = ANY(sqlc.arg(post_ids)::int[])
+ORDER BY post_id, id;
+
+-- name: GetEnclosuresForFeed :many
+SELECT enclosures.* FROM enclosures
+JOIN posts ON enclosures.post_id = posts.id
+WHERE posts.feed_id = $1
+ORDER BY posts.published_at DESC, enclosures.id
+LIMIT $2;
+
+-- name: GetFeedByURL :one
+SELECT * FROM feeds
+WHERE url = $1;
+
+-- name: FollowFeed :one
+INSERT INTO feed_follows (user_id, feed_id)
+VALUES ($1, $2)
+ON CONFLICT (user_id, feed_id) DO UPDATE
+SET updated_at = feed_follows.updated_at
+RETURNING id, (xmax = 0) AS inserted;
+
+-- name: UpsertFolder :one
+INSERT INTO folders (user_id, name)
+VALUES ($1, $2)
+ON CONFLICT (user_id, name) DO UPDATE
+SET updated_at = folders.updated_at
+RETURNING id;
+
+-- name: AddFeedFollowToFolder :exec
+INSERT INTO feed_follow_folders (feed_follow_id, folder_id)
+VALUES ($1, $2)
+ON CONFLICT DO NOTHING;
+
+-- name: GetFollowedFeedsWithFolders :many
+SELECT
+    feeds.name,
+    feeds.url,
+    COALESCE(
+        array_agg(folders.name ORDER BY folders.name) FILTER (WHERE folders.name IS NOT NULL),
+        '{}'
+    )::text[] AS folders
+FROM feed_follows
+JOIN feeds ON feed_follows.feed_id = feeds.id
+LEFT JOIN feed_follow_folders ON feed_follow_folders.feed_follow_id = feed_follows.id
+LEFT JOIN folders ON feed_follow_folders.folder_id = folders.id
+WHERE feed_follows.user_id = $1
+GROUP BY feeds.id
+ORDER BY feeds.name;
+
+-- name: GetPostForUser :one
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description,
+    posts.published_at, posts.feed_id, posts.guid, posts.content, posts.author, posts.comments_url,
+    posts.categories,
+    feeds.name AS feed_name,
+    post_states.read_at,
+    post_states.starred_at
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE posts.id = $1 AND feed_follows.user_id = $2;
+
+-- name: MarkPostRead :exec
+INSERT INTO post_states (user_id, post_id, read_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = COALESCE(post_states.read_at, NOW()),
+    updated_at = NOW();
+
+-- name: MarkPostsRead :execrows
+-- Marks the user's unread posts as read, limited to one feed and to posts
+-- published before a time when those are given.
+INSERT INTO post_states (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, NOW()
+FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+  AND (sqlc.narg(feed_id)::int IS NULL OR posts.feed_id = sqlc.narg(feed_id))
+  AND (sqlc.narg(before)::timestamp IS NULL OR posts.published_at < sqlc.narg(before))
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = NOW(),
+    updated_at = NOW()
+WHERE post_states.read_at IS NULL;
+
+-- name: StarPost :exec
+INSERT INTO post_states (user_id, post_id, starred_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET starred_at = COALESCE(post_states.starred_at, NOW()),
+    updated_at = NOW();
+
+-- name: UnstarPost :execrows
+-- Starred posts outlive unfollowing, so unstarring doesn't need the post's
+-- feed to be followed.
+UPDATE post_states
+SET starred_at = NULL,
+    updated_at = NOW()
+WHERE user_id = $1 AND post_id = $2 AND starred_at IS NOT NULL;
+
+-- name: GetStarredPostsForUser :many
+-- Starred posts stay listed after their feed is unfollowed, so this
+-- doesn't join feed_follows.
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description,
+    posts.published_at, posts.feed_id, posts.guid, posts.content, posts.author, posts.comments_url,
+    posts.categories,
+    feeds.name AS feed_name,
+    post_states.starred_at
+FROM post_states
+JOIN posts ON post_states.post_id = posts.id
+JOIN feeds ON posts.feed_id = feeds.id
+WHERE post_states.user_id = $1 AND post_states.starred_at IS NOT NULL
+ORDER BY post_states.starred_at DESC
+LIMIT $2 OFFSET $3;
+
+-- name: SearchPosts :many
+-- Ranks the user's posts matching a web search style query (quoted
+-- phrases, OR, -excluded) and highlights the matches in a snippet.
+SELECT
+    posts.id, posts.title, posts.url, posts.published_at,
+    feeds.name AS feed_name,
+    ts_rank(posts.search_vector, query)::real AS rank,
+    ts_headline(
+        'english',
+        coalesce(posts.content, posts.description, posts.title),
+        query,
+        'StartSel=**, StopSel=**, MaxFragments=2, MaxWords=25, MinWords=10, FragmentDelimiter=" ... "'
+    )::text AS snippet
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = feeds.id,
+    websearch_to_tsquery('english', sqlc.arg(query)) AS query
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+  AND posts.search_vector @@ query
+  AND (sqlc.narg(folder)::text IS NULL OR EXISTS (
+      SELECT 1 FROM feed_follow_folders
+      JOIN folders ON feed_follow_folders.folder_id = folders.id
+      WHERE feed_follow_folders.feed_follow_id = feed_follows.id
+        AND (folders.name = sqlc.narg(folder) OR left(folders.name, length(sqlc.narg(folder)) + 1) = sqlc.narg(folder) || '/')
+  ))
+ORDER BY rank DESC, posts.published_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetFeedFollow :one
+SELECT * FROM feed_follows
+WHERE user_id = $1 AND feed_id = $2;
+
+-- name: RemoveFeedFollowFromFolder :execrows
+DELETE FROM feed_follow_folders
+USING folders
+WHERE feed_follow_folders.folder_id = folders.id
+  AND feed_follow_folders.feed_follow_id = $1
+  AND folders.user_id = $2
+  AND folders.name = $3;
+
+-- name: DeleteEmptyFolders :exec
+DELETE FROM folders
+WHERE user_id = $1
+  AND NOT EXISTS (
+      SELECT 1 FROM feed_follow_folders
+      WHERE feed_follow_folders.folder_id = folders.id
+  );
+
+-- name: GetFoldersForUser :many
+SELECT
+    folders.id,
+    folders.name,
+    COUNT(feed_follow_folders.feed_follow_id) AS feed_count
+FROM folders
+LEFT JOIN feed_follow_folders ON feed_follow_folders.folder_id = folders.id
+WHERE folders.user_id = $1
+GROUP BY folders.id
+ORDER BY folders.name;
+
+-- name: SetFeedRetention :exec
+UPDATE feeds
+SET retention_max_age_seconds = $2,
+    retention_max_posts = $3,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetFeedRetentions :many
+SELECT id, name, retention_max_age_seconds, retention_max_posts FROM feeds
+ORDER BY name;
+
+-- name: PruneExpiredPosts :execrows
+-- Deletes up to batch_size posts of a feed published before a time,
+-- skipping posts anyone has starred.
+DELETE FROM posts
+WHERE id IN (
+    SELECT posts.id FROM posts
+    WHERE posts.feed_id = sqlc.arg(feed_id)
+      AND posts.published_at < sqlc.arg(before)
+      AND NOT EXISTS (
+          SELECT 1 FROM post_states
+          WHERE post_states.post_id = posts.id
+            AND post_states.starred_at IS NOT NULL
+      )
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: PruneExcessPosts :execrows
+-- Deletes up to batch_size posts of a feed beyond its newest keep posts,
+-- skipping posts anyone has starred. Starred posts still count towards
+-- the posts kept.
+DELETE FROM posts
+WHERE id IN (
+    SELECT older.id FROM (
+        SELECT posts.id FROM posts
+        WHERE posts.feed_id = sqlc.arg(feed_id)
+        ORDER BY posts.published_at DESC, posts.id DESC
+        OFFSET sqlc.arg(keep)
+    ) AS older
+    WHERE NOT EXISTS (
+        SELECT 1 FROM post_states
+        WHERE post_states.post_id = older.id
+          AND post_states.starred_at IS NOT NULL
+    )
+    LIMIT sqlc.arg(batch_size)
+);
+
+-- name: MarkPostUnread :execrows
+UPDATE post_states
+SET read_at = NULL,
+    updated_at = NOW()
+WHERE user_id = $1 AND post_id = $2 AND read_at IS NOT NULL;
+
+-- name: CreateAPIToken :one
+INSERT INTO api_tokens (user_id, name, token_hash)
+VALUES ($1, $2, $3)
+RETURNING *;
+
+-- name: GetAPITokensForUser :many
+SELECT * FROM api_tokens
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: DeleteAPIToken :execrows
+DELETE FROM api_tokens
+WHERE id = $1 AND user_id = $2;
+
+-- name: DeleteOldAPITokens :execrows
+-- Deletes a user's tokens with the given name except the newest keep.
+DELETE FROM api_tokens
+WHERE api_tokens.user_id = sqlc.arg(user_id)
+  AND api_tokens.name = sqlc.arg(name)
+  AND api_tokens.id NOT IN (
+      SELECT newest.id FROM api_tokens AS newest
+      WHERE newest.user_id = sqlc.arg(user_id) AND newest.name = sqlc.arg(name)
+      ORDER BY newest.created_at DESC, newest.id DESC
+      LIMIT sqlc.arg(keep)
+  );
+
+-- name: GetUserByAPIToken :one
+-- Finds the user a token belongs to and records that the token was used.
+WITH token AS (
+    UPDATE api_tokens
+    SET last_used_at = NOW()
+    WHERE token_hash = $1
+    RETURNING user_id
+)
+SELECT users.* FROM users
+JOIN token ON token.user_id = users.id;
+
+-- name: SetFeverKey :exec
+INSERT INTO fever_keys (user_id, key_hash)
+VALUES ($1, $2)
+ON CONFLICT (user_id) DO UPDATE
+SET key_hash = EXCLUDED.key_hash,
+    updated_at = NOW();
+
+-- name: DeleteFeverKey :execrows
+DELETE FROM fever_keys
+WHERE user_id = $1;
+
+-- name: GetUserByFeverKey :one
+SELECT users.* FROM users
+JOIN fever_keys ON fever_keys.user_id = users.id
+WHERE fever_keys.key_hash = $1;
+
+-- name: GetFollowedFeeds :many
+SELECT feeds.* FROM feeds
+JOIN feed_follows ON feed_follows.feed_id = feeds.id
+WHERE feed_follows.user_id = $1
+ORDER BY feeds.name;
+
+-- name: GetFolderFeedsForUser :many
+SELECT feed_follow_folders.folder_id, feed_follows.feed_id
+FROM feed_follow_folders
+JOIN feed_follows ON feed_follow_folders.feed_follow_id = feed_follows.id
+WHERE feed_follows.user_id = $1
+ORDER BY feed_follow_folders.folder_id, feed_follows.feed_id;
+
+-- name: GetPostsByIDForUser :many
+-- Lists posts of the user's followed feeds by ID: those with the given IDs,
+-- or else those after since_id in ascending order, or else those before
+-- max_id in descending order.
+SELECT
+    posts.id, posts.created_at, posts.updated_at, posts.title, posts.url, posts.description,
+    posts.published_at, posts.feed_id, posts.guid, posts.content, posts.author, posts.comments_url,
+    posts.categories,
+    feeds.name AS feed_name,
+    post_states.read_at,
+    post_states.starred_at
+FROM posts
+JOIN feeds ON posts.feed_id = feeds.id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+  AND (sqlc.narg(ids)::int[] IS NULL OR posts.id = ANY(sqlc.narg(ids)::int[]))
+  AND (sqlc.narg(since_id)::int IS NULL OR posts.id > sqlc.narg(since_id))
+  AND (sqlc.narg(max_id)::int IS NULL OR posts.id < sqlc.narg(max_id))
+ORDER BY CASE WHEN sqlc.narg(max_id)::int IS NULL THEN posts.id ELSE -posts.id END
+LIMIT sqlc.arg('limit');
+
+-- name: CountPostsForUser :one
+SELECT COUNT(*) FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+WHERE feed_follows.user_id = $1;
+
+-- name: GetUnreadPostIDs :many
+SELECT posts.id FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = $1 AND post_states.read_at IS NULL
+ORDER BY posts.id;
+
+-- name: GetStarredPostIDs :many
+SELECT post_states.post_id FROM post_states
+JOIN posts ON post_states.post_id = posts.id
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id AND feed_follows.user_id = post_states.user_id
+WHERE post_states.user_id = $1 AND post_states.starred_at IS NOT NULL
+ORDER BY post_states.post_id;
+
+-- name: MarkFolderRead :execrows
+-- Marks the user's unread posts in the feeds of one folder as read,
+-- limited to posts published before a time when one is given.
+INSERT INTO post_states (user_id, post_id, read_at)
+SELECT feed_follows.user_id, posts.id, NOW()
+FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+JOIN feed_follow_folders ON feed_follow_folders.feed_follow_id = feed_follows.id
+WHERE feed_follows.user_id = $1
+  AND feed_follow_folders.folder_id = $2
+  AND (sqlc.narg(before)::timestamp IS NULL OR posts.published_at < sqlc.narg(before))
+ON CONFLICT (user_id, post_id) DO UPDATE
+SET read_at = NOW(),
+    updated_at = NOW()
+WHERE post_states.read_at IS NULL;
+
+-- name: GetStreamPostIDs :many
+-- Lists the IDs of posts in the user's followed feeds for a Google Reader
+-- stream, optionally limited to one feed or folder, to starred posts, to
+-- read or unread posts and to a range of publication times. Posts are
+-- listed newest first unless oldest_first is set.
+SELECT posts.id FROM posts
+JOIN feed_follows ON feed_follows.feed_id = posts.feed_id
+LEFT JOIN post_states ON post_states.post_id = posts.id AND post_states.user_id = feed_follows.user_id
+WHERE feed_follows.user_id = sqlc.arg(user_id)
+  AND (sqlc.narg(feed_id)::int IS NULL OR posts.feed_id = sqlc.narg(feed_id))
+  AND (sqlc.narg(folder)::text IS NULL OR EXISTS (
+      SELECT 1 FROM feed_follow_folders
+      JOIN folders ON feed_follow_folders.folder_id = folders.id
+      WHERE feed_follow_folders.feed_follow_id = feed_follows.id
+        AND (folders.name = sqlc.narg(folder) OR left(folders.name, length(sqlc.narg(folder)) + 1) = sqlc.narg(folder) || '/')
+  ))
+  AND (NOT sqlc.arg(starred_only)::bool OR post_states.starred_at IS NOT NULL)
+  AND (sqlc.narg(read)::bool IS NULL OR (post_states.read_at IS NOT NULL) = sqlc.narg(read))
+  AND (sqlc.narg(published_after)::timestamp IS NULL OR posts.published_at >= sqlc.narg(published_after))
+  AND (sqlc.narg(published_before)::timestamp IS NULL OR posts.published_at < sqlc.narg(published_before))
+ORDER BY
+    CASE WHEN sqlc.arg(oldest_first)::bool THEN posts.published_at END,
+    posts.published_at DESC,
+    posts.id DESC
+LIMIT sqlc.arg('limit') OFFSET sqlc.arg('offset');
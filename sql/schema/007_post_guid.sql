@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE posts
+ADD COLUMN guid TEXT;
+
+UPDATE posts SET guid = url;
+
+DELETE FROM posts a
+USING posts b
+WHERE a.feed_id = b.feed_id
+  AND a.guid = b.guid
+  AND a.id > b.id;
+
+ALTER TABLE posts
+ALTER COLUMN guid SET NOT NULL,
+ADD CONSTRAINT posts_feed_id_guid_key UNIQUE (feed_id, guid);
+
+-- +goose Down
+ALTER TABLE posts
+DROP CONSTRAINT posts_feed_id_guid_key,
+DROP COLUMN guid;
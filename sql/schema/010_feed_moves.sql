@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN moved_to_url TEXT,
+ADD COLUMN moved_count INTEGER NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN moved_to_url,
+DROP COLUMN moved_count;
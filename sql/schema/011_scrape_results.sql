@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN last_scrape_inserted INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN last_scrape_updated INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN last_scrape_skipped INTEGER NOT NULL DEFAULT 0,
+ADD COLUMN last_scrape_errors TEXT;
+
+-- +goose Down
+ALTER TABLE feeds
+DROP COLUMN last_scrape_inserted,
+DROP COLUMN last_scrape_updated,
+DROP COLUMN last_scrape_skipped,
+DROP COLUMN last_scrape_errors;
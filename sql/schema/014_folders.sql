@@ -0,0 +1,19 @@
+-- +goose Up
+CREATE TABLE folders (
+    id SERIAL PRIMARY KEY,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    UNIQUE (user_id, name)
+);
+
+CREATE TABLE feed_follow_folders (
+    feed_follow_id INTEGER NOT NULL REFERENCES feed_follows(id) ON DELETE CASCADE,
+    folder_id INTEGER NOT NULL REFERENCES folders(id) ON DELETE CASCADE,
+    PRIMARY KEY (feed_follow_id, folder_id)
+);
+
+-- +goose Down
+DROP TABLE feed_follow_folders;
+DROP TABLE folders;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE post_states
+ADD COLUMN starred_at TIMESTAMP;
+
+CREATE INDEX post_states_starred_idx ON post_states (user_id, starred_at)
+WHERE starred_at IS NOT NULL;
+
+-- +goose Down
+DROP INDEX post_states_starred_idx;
+
+ALTER TABLE post_states
+DROP COLUMN starred_at;
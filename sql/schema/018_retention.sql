@@ -0,0 +1,13 @@
+-- +goose Up
+ALTER TABLE feeds
+ADD COLUMN retention_max_age_seconds INTEGER,
+ADD COLUMN retention_max_posts INTEGER;
+
+CREATE INDEX posts_feed_published_idx ON posts (feed_id, published_at DESC, id DESC);
+
+-- +goose Down
+DROP INDEX posts_feed_published_idx;
+
+ALTER TABLE feeds
+DROP COLUMN retention_max_age_seconds,
+DROP COLUMN retention_max_posts;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE fever_keys (
+    user_id INTEGER PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    api_key TEXT UNIQUE NOT NULL
+);
+
+-- +goose Down
+DROP TABLE fever_keys;
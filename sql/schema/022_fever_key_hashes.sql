@@ -0,0 +1,10 @@
+-- +goose Up
+-- Keep only a hash of each Fever key, as api_tokens does for API tokens.
+ALTER TABLE fever_keys RENAME COLUMN api_key TO key_hash;
+UPDATE fever_keys SET key_hash = encode(sha256(convert_to(key_hash, 'UTF8')), 'hex');
+
+-- +goose Down
+-- The keys can't be recovered from their hashes, so Fever access has to be
+-- enabled again.
+DELETE FROM fever_keys;
+ALTER TABLE fever_keys RENAME COLUMN key_hash TO api_key;